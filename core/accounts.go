@@ -17,72 +17,192 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"regexp"
+	"strings"
+	"unicode/utf8"
 
-	"github.com/dghubble/sling"
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/edgexfoundry/edgexsecurity/kong"
 )
 
-func isAllowedChars(user string) bool {
-	return regexp.MustCompile(`^[a-zA-Z]+$`).MatchString(user)
+// the username policy when [usernames] sets no pattern
+const defaultUsernamePattern = `^[a-zA-Z]+$`
+
+// validUsername checks the username against the [usernames] policy
+func validUsername(config *tomlConfig, user string) error {
+	policy := config.Usernames
+	pattern := policy.Pattern
+	if pattern == "" {
+		pattern = defaultUsernamePattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		s := fmt.Sprintf("Invalid username pattern %s with error %s.", pattern, err.Error())
+		return errors.New(s)
+	}
+	if !re.MatchString(user) {
+		s := fmt.Sprintf("User name %s does not match the pattern %s.", user, pattern)
+		return errors.New(s)
+	}
+	// whatever the pattern allows, the name becomes a segment of the secret service path
+	if user == "." || user == ".." || strings.ContainsAny(user, "/\\?#%") {
+		s := fmt.Sprintf("User name %s must not be . or .. or contain any of / \\ ? # %%.", user)
+		return errors.New(s)
+	}
+	if utf8.RuneCountInString(user) < policy.MinLength {
+		s := fmt.Sprintf("User name %s is shorter than %d characters.", user, policy.MinLength)
+		return errors.New(s)
+	}
+	if policy.MaxLength > 0 && utf8.RuneCountInString(user) > policy.MaxLength {
+		s := fmt.Sprintf("User name %s is longer than %d characters.", user, policy.MaxLength)
+		return errors.New(s)
+	}
+	for _, reserved := range policy.Reserved {
+		if strings.EqualFold(user, reserved) {
+			s := fmt.Sprintf("User name %s is reserved.", user)
+			return errors.New(s)
+		}
+	}
+	return nil
 }
 
-func createConsumer(user string, url string, service string, c *http.Client) error {
+// createConsumer adds the consumer unless it exists, customID links it to an id of another
+// system such as an asset id and may be empty
+func createConsumer(config *tomlConfig, user string, customID string, kc *kong.Client, service string) error {
 
-	if !isAllowedChars(user) {
-		s := "Only a-z and A-Z char are allowed for user name."
-		return errors.New(s)
+	if err := validUsername(config, user); err != nil {
+		return err
 	}
-	userNameParams := &KongUser{UserName: user}
-	req, err := sling.New().Base(url).Post(ConsumersPath).BodyForm(userNameParams).Request()
-	resp, err := c.Do(req)
-	if err != nil {
+	_, err := kc.CreateConsumer(&kong.Consumer{Username: user, CustomID: customID})
+	if err != nil && !kong.IsConflict(err) {
 		s := fmt.Sprintf("Failed to create consumer %s for %s service with error %s.", user, service, err.Error())
+		lc.Error(s)
 		return errors.New(s)
 	}
-	if resp.StatusCode == 200 || resp.StatusCode == 201 || resp.StatusCode == 409 {
-		lc.Info(fmt.Sprintf("Successful to create consumer %s for %s service.", user, service))
+	if err != nil && customID != "" {
+		// the consumer exists already, it must not be linked to something else silently
+		existing, err := kc.GetConsumer(user)
+		if err != nil {
+			s := fmt.Sprintf("Failed to retrieve consumer %s with error %s.", user, err.Error())
+			lc.Error(s)
+			return errors.New(s)
+		}
+		if existing.CustomID != customID {
+			s := fmt.Sprintf("Consumer %s exists already with custom id %s instead of %s.", user, existing.CustomID, customID)
+			lc.Error(s)
+			return errors.New(s)
+		}
+	}
+	lc.Info(fmt.Sprintf("Successful to create consumer %s for %s service.", user, service))
+	return nil
+}
+
+// deleteConsumer succeeds as well when the consumer is gone already, so that what is stored for
+// it elsewhere can still be cleaned up
+func deleteConsumer(user string, kc *kong.Client) error {
+	err := kc.DeleteConsumer(user)
+	if kong.IsNotFound(err) {
+		lc.Info(fmt.Sprintf("Consumer %s does not exist anymore.", user))
 		return nil
 	}
-	s := fmt.Sprintf("Failed to create consumer %s for %s service.", user, service)
-	lc.Error(s)
-	return errors.New(s)
+	if err != nil {
+		s := fmt.Sprintf("Failed to delete consumer %s with error %s.", user, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	lc.Info(fmt.Sprintf("Successful to delete consumer %s.", user))
+	return nil
 }
 
-func deleteConsumer(user string, url string, c *http.Client) {
-	deleteResource(user, url, ConsumersPath, ConsumersPath, c)
+// issuedJWT is a token together with the credential it was signed for
+type issuedJWT struct {
+	Token      string
+	PrivateKey string
+	Credential *kong.JWTCredential
 }
 
-func createJWTForConsumer(user string, url string, name string, c *http.Client) (string, error) {
-	jwtCred := JWTCred{}
-	s := sling.New().Set("Content-Type", "application/x-www-form-urlencoded")
-	req, err := s.New().Get(url).Post(fmt.Sprintf("consumers/%s/jwt", user)).Request()
-	resp, err := c.Do(req)
+// createJWTForConsumer adds a jwt credential to the consumer and returns a token signed with
+// it. For RS256 and ES256 the key pair is generated here, Kong only receives the public key and
+// the private key is returned PEM encoded so the user can sign further tokens. With a transit
+// signer the key pair stays in the secret service instead and no private key is returned.
+func createJWTForConsumer(user string, kc *kong.Client, name string, opts tokenOptions) (*issuedJWT, error) {
+	alg := opts.Algorithm
+	if alg == "" {
+		alg = "HS256"
+	}
+	method, ok := signingMethods[alg]
+	if !ok {
+		return nil, validAlgorithm(alg)
+	}
+
+	// one credential per token, keyed by the jti, so a single token can be revoked
+	if opts.ID == "" {
+		var err error
+		if opts.ID, err = newTokenID(); err != nil {
+			return nil, err
+		}
+	}
+	cred := &kong.JWTCredential{Key: opts.ID}
+	var key *signingKey
+	if opts.Signer != nil {
+		public, err := opts.Signer.publicKey(user, alg)
+		if err != nil {
+			return nil, err
+		}
+		cred.Algorithm = alg
+		cred.RSAPublicKey = public
+	} else if alg != "HS256" {
+		var err error
+		key, err = generateSigningKey(alg)
+		if err != nil {
+			errString := fmt.Sprintf("Failed to generate %s key pair for consumer %s with error %s.", alg, user, err.Error())
+			return nil, errors.New(errString)
+		}
+		cred.Algorithm = alg
+		cred.RSAPublicKey = key.publicPEM
+	}
+
+	jwtCred, err := kc.CreateJWTCredential(user, cred)
 	if err != nil {
 		errString := fmt.Sprintf("Failed to create jwt token for consumer %s with error %s.", user, err.Error())
-		return "", errors.New(errString)
+		return nil, errors.New(errString)
 	}
-	if resp.StatusCode == 200 || resp.StatusCode == 201 || resp.StatusCode == 409 {
-		defer resp.Body.Close()
-		json.NewDecoder(resp.Body).Decode(&jwtCred)
-		lc.Info(fmt.Sprintf("successful on retrieving JWT credential for consumer %s.", user))
+	lc.Info(fmt.Sprintf("successful on retrieving JWT credential for consumer %s.", user))
 
-		// Create the Claims
-		claims := KongJWTClaims{
-			jwtCred.Key,
-			user,
-			jwt.StandardClaims{
-				Issuer: EdgeXService,
-			},
-		}
+	// Create the Claims
+	claims, err := tokenClaims(user, jwtCred.Key, opts)
+	if err != nil {
+		return nil, err
+	}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		return token.SignedString([]byte(jwtCred.Secret))
+	token := jwt.NewWithClaims(method, claims)
+	issued := &issuedJWT{Credential: jwtCred}
+	switch {
+	case opts.Signer != nil:
+		issued.Token, err = opts.Signer.sign(user, alg, token)
+	case key != nil:
+		issued.Token, err = token.SignedString(key.private)
+		issued.PrivateKey = key.privatePEM
+	default:
+		issued.Token, err = token.SignedString([]byte(jwtCred.Secret))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// outputJWT prints the token, masked is set when it is kept in the secret service
+func outputJWT(user string, issued *issuedJWT, keyFile string, masked bool) error {
+	if masked {
+		fmt.Println(fmt.Sprintf("The JWT for user %s is stored in the secret service: %s.", user, secretOutput(issued.Token, masked)))
+	} else {
+		fmt.Println(fmt.Sprintf("The JWT for user %s is: %s. Please keep the jwt for accessing edgex services.", user, issued.Token))
+	}
+	if issued.PrivateKey == "" || (masked && keyFile == "") {
+		return nil
 	}
-	errString := fmt.Sprintf("Failed to create JWT for consumer %s with errorCode %d.", user, resp.StatusCode)
-	return "", errors.New(errString)
+	return outputPrivateKey(user, issued.PrivateKey, keyFile)
 }
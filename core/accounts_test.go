@@ -0,0 +1,146 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+func TestValidUsername(t *testing.T) {
+	policy := usernames{Pattern: `^[\p{L}0-9][\p{L}0-9._-]*$`, MinLength: 3, MaxLength: 6, Reserved: []string{"admin"}}
+	tests := []struct {
+		user  string
+		valid bool
+	}{
+		{"gw-01", true},
+		{"jürgen", true},
+		{"größe", true},
+		{"jürgens", false},
+		{"ab", false},
+		{"Admin", false},
+		{"-gw", false},
+		{"gw/01", false},
+		{"..", false},
+	}
+	for _, tt := range tests {
+		err := validUsername(&tomlConfig{Usernames: policy}, tt.user)
+		if (err == nil) != tt.valid {
+			t.Errorf("validUsername(%q) = %v, want valid %v", tt.user, err, tt.valid)
+		}
+	}
+
+	// a pattern that lets anything through still keeps the name a single path segment
+	open := &tomlConfig{Usernames: usernames{Pattern: ".*"}}
+	for _, user := range []string{"a/b", ".", "..", `a\b`, "a?b", "a#b", "a%2Fb"} {
+		if validUsername(open, user) == nil {
+			t.Errorf("validUsername(%q) accepted a name that is not a path segment", user)
+		}
+	}
+	if validUsername(&tomlConfig{}, "guest") != nil || validUsername(&tomlConfig{}, "gw-01") == nil {
+		t.Error("the default pattern allows letters only")
+	}
+}
+
+func TestKVPath(t *testing.T) {
+	tests := []struct {
+		path string
+		user string
+		want string
+	}{
+		{"secret/edgex/apikeys", "guest", "secret/edgex/apikeys/guest"},
+		{"secret/edgex/apikeys/", "guest", "secret/edgex/apikeys/guest"},
+		{"secret/edgex/apikeys", "gw.01", "secret/edgex/apikeys/gw.01"},
+		{"secret/edgex/apikeys", "", ""},
+		{"secret/edgex/apikeys", "..", ""},
+		{"secret/edgex/apikeys", "../tokens", ""},
+		{"secret/edgex/apikeys", "a/b", ""},
+	}
+	for _, tt := range tests {
+		got, err := kvPath(tt.path, tt.user)
+		if got != tt.want || (err == nil) != (tt.want != "") {
+			t.Errorf("kvPath(%q, %q) = %q, %v, want %q", tt.path, tt.user, got, err, tt.want)
+		}
+	}
+}
+
+// existingConsumer is a Kong that already has the consumer gateway linked to asset-1
+func existingConsumer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && strings.TrimSuffix(r.URL.Path, "/") == "/consumers":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"username": "already exists with value 'gateway'"}`))
+		case r.Method == "GET" && r.URL.Path == "/consumers/gateway":
+			json.NewEncoder(w).Encode(kong.Consumer{ID: "consumer-1", Username: "gateway", CustomID: "asset-1"})
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestCreateConsumerCustomIDConflict(t *testing.T) {
+	server := existingConsumer(t)
+	defer server.Close()
+	kc := kong.NewClient(server.URL+"/", &http.Client{})
+	config := &tomlConfig{Usernames: usernames{Pattern: "^[a-z]+$"}}
+
+	tests := []struct {
+		customID string
+		ok       bool
+	}{
+		{"", true},
+		{"asset-1", true},
+		{"asset-2", false},
+	}
+	for _, tt := range tests {
+		err := createConsumer(config, "gateway", tt.customID, kc, EdgeXService)
+		if (err == nil) != tt.ok {
+			t.Errorf("createConsumer with custom id %q = %v, want ok %v", tt.customID, err, tt.ok)
+		}
+	}
+}
+
+func TestDeleteConsumer(t *testing.T) {
+	tests := []struct {
+		status int
+		ok     bool
+	}{
+		{http.StatusNoContent, true},
+		{http.StatusNotFound, true},
+		{http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "DELETE" || r.URL.Path != "/consumers/guest" {
+				t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			}
+			w.WriteHeader(tt.status)
+		}))
+		err := deleteConsumer("guest", kong.NewClient(server.URL+"/", &http.Client{}))
+		server.Close()
+		if (err == nil) != tt.ok {
+			t.Errorf("deleteConsumer with kong answering %d = %v, want ok %v", tt.status, err, tt.ok)
+		}
+	}
+}
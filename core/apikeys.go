@@ -0,0 +1,190 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+const apiKeyBytes = 32
+
+// apiKeyStore keeps a copy of the api keys of every consumer in the vault kv secret
+// <apikeypath><username>, mapping the credential id to the key.
+type apiKeyStore struct {
+	kv   *vaultKV
+	path string
+}
+
+// newAPIKeyStore returns nil when the keys are not to be stored in the secret service
+func newAPIKeyStore(config *tomlConfig, secretBaseURL string, c *http.Client) (*apiKeyStore, error) {
+	if !config.SecretService.StoreAPIKeys {
+		return nil, nil
+	}
+	kv, err := newVaultKV(config, secretBaseURL, c)
+	if err != nil {
+		return nil, err
+	}
+	return &apiKeyStore{kv: kv, path: config.SecretService.APIKeyPath}, nil
+}
+
+func (k *apiKeyStore) read(user string) (map[string]string, error) {
+	keys := map[string]string{}
+	path, err := kvPath(k.path, user)
+	if err != nil {
+		return nil, err
+	}
+	_, err = k.kv.read(path, &keys)
+	return keys, err
+}
+
+func (k *apiKeyStore) write(user string, keys map[string]string) error {
+	path, err := kvPath(k.path, user)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return k.kv.remove(path)
+	}
+	return k.kv.write(path, keys)
+}
+
+func (k *apiKeyStore) add(user string, id string, key string) error {
+	keys, err := k.read(user)
+	if err != nil {
+		return err
+	}
+	keys[id] = key
+	return k.write(user, keys)
+}
+
+func (k *apiKeyStore) remove(user string, id string) error {
+	keys, err := k.read(user)
+	if err != nil {
+		return err
+	}
+	if _, ok := keys[id]; !ok {
+		return nil
+	}
+	delete(keys, id)
+	return k.write(user, keys)
+}
+
+// clear removes all stored keys of a deleted consumer
+func (k *apiKeyStore) clear(user string) error {
+	return k.write(user, nil)
+}
+
+func outputAPIKey(user string, key string, masked bool) {
+	fmt.Println(fmt.Sprintf("The api key for user %s is: %s.", user, secretOutput(key, masked)))
+}
+
+func newAPIKey() (string, error) {
+	b := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// createAPIKey adds a generated key to the consumer, a consumer can hold any number of keys.
+// The key is only stored when the secret service keeps api keys.
+func createAPIKey(kc *kong.Client, user string, store *apiKeyStore) (*kong.KeyAuthCredential, error) {
+	key, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	cred, err := kc.CreateKeyAuthCredential(user, &kong.KeyAuthCredential{Key: key})
+	if err != nil {
+		return nil, err
+	}
+	// Kong echoes the key, but a planned request answers with an empty object
+	cred.Key = key
+
+	if store != nil && cred.ID != "" {
+		if err := store.add(user, cred.ID, key); err != nil {
+			return cred, err
+		}
+		lc.Info(fmt.Sprintf("Successful to store api key %s of consumer %s in the secret service.", cred.ID, user))
+	}
+	return cred, nil
+}
+
+// listAPIKeys lists the keys of the consumer without the key values
+func listAPIKeys(kc *kong.Client, user string) ([]credentialListing, error) {
+	creds, err := kc.ListKeyAuthCredentials(user)
+	if err != nil {
+		s := fmt.Sprintf("Failed to read api keys of consumer %s with error %s.", user, err.Error())
+		lc.Error(s)
+		return nil, errors.New(s)
+	}
+	listing := []credentialListing{}
+	for _, c := range creds {
+		listing = append(listing, credentialListing{
+			ID:        c.ID,
+			Key:       maskKey(c.Key),
+			CreatedAt: formatKongTime(c.CreatedAt),
+		})
+	}
+	return listing, nil
+}
+
+// only the start of a key is shown so a listing can't be used to call the services
+func maskKey(key string) string {
+	if len(key) <= 6 {
+		return "******"
+	}
+	return key[:6] + "******"
+}
+
+// revokeAPIKey deletes the key with the given id or key value from the consumer and the store
+func revokeAPIKey(kc *kong.Client, user string, idOrKey string, store *apiKeyStore) error {
+	creds, err := kc.ListKeyAuthCredentials(user)
+	if err != nil {
+		s := fmt.Sprintf("Failed to read api keys of consumer %s with error %s.", user, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	var cred *kong.KeyAuthCredential
+	for i := range creds {
+		if creds[i].ID == idOrKey || creds[i].Key == idOrKey {
+			cred = &creds[i]
+			break
+		}
+	}
+	if cred == nil {
+		err := credentialNotFound{kind: KeyAuthPlugin, user: user, idOrKey: idOrKey}
+		lc.Error(err.Error())
+		return err
+	}
+
+	if err := kc.DeleteKeyAuthCredential(user, cred.ID); err != nil {
+		s := fmt.Sprintf("Failed to revoke api key %s of consumer %s with error %s.", cred.ID, user, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	lc.Info(fmt.Sprintf("Successful to revoke api key %s of consumer %s.", cred.ID, user))
+	if store != nil {
+		return store.remove(user, cred.ID)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+const (
+	KeyAuthPlugin   = "key-auth"
+	BasicAuthPlugin = "basic-auth"
+	HMACAuthPlugin  = "hmac-auth"
+	OAuth2Plugin    = "oauth2"
+	NoAuth          = "none"
+)
+
+// the authentication plugins a service can be protected with, only one is installed per service
+var authPlugins = []string{JWTPlugin, KeyAuthPlugin, BasicAuthPlugin, HMACAuthPlugin, OAuth2Plugin, LDAPAuthPlugin}
+
+// the config fields this tool manages on each plugin, sync compares and updates only these
+var managedPluginFields = map[string][]string{
+	JWTPlugin:       {"claims_to_verify"},
+	KeyAuthPlugin:   {"key_names"},
+	BasicAuthPlugin: {"hide_credentials"},
+	HMACAuthPlugin:  {},
+	OAuth2Plugin:    {"enable_client_credentials", "token_expiration", "global_credentials"},
+	LDAPAuthPlugin:  {"ldap_host", "ldap_port", "start_tls", "verify_ldap_host", "base_dn", "attribute", "timeout", "cache_ttl", "hide_credentials"},
+	ACLPlugin:       {"whitelist"},
+}
+
+func serviceAuthentication(s service) string {
+	if s.Authentication == "" {
+		return JWTPlugin
+	}
+	return s.Authentication
+}
+
+// configService finds an edgex service by its name in the reverse proxy, which need not be
+// its key in [edgexservices]
+func configService(config *tomlConfig, name string) (service, bool) {
+	if s, ok := config.EdgexServices[name]; ok && s.Name == name {
+		return s, true
+	}
+	for _, s := range config.EdgexServices {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return service{}, false
+}
+
+// authentication returns the authentication of the edgex service or the admin loopback, which
+// always uses jwt so that the acl plugin can check the role of the consumer
+func authentication(config *tomlConfig, name string) string {
+	if s, ok := configService(config, name); ok {
+		return serviceAuthentication(s)
+	}
+	return JWTPlugin
+}
+
+// adminDirectory tells whether the service is the admin loopback and [ldap] admin asks for the
+// directory password on top of its jwt
+func adminDirectory(config *tomlConfig, name string) bool {
+	return name == AdminService && config.LDAP.Admin
+}
+
+func validAuthentication(config *tomlConfig) error {
+	ldap := config.LDAP.Admin
+	for name, svc := range config.EdgexServices {
+		auth := serviceAuthentication(svc)
+		if auth != NoAuth && !containsString(authPlugins, auth) {
+			s := fmt.Sprintf("Unsupported authentication %s for service %s, expected jwt, key-auth, basic-auth, hmac-auth, oauth2, ldap-auth or none.", auth, name)
+			return errors.New(s)
+		}
+		ldap = ldap || auth == LDAPAuthPlugin
+	}
+	if ldap {
+		return validLDAP(config)
+	}
+	return nil
+}
+
+// the header or query parameter the api key is read from when a service sets no keynames
+var defaultKeyNames = []string{"apikey"}
+
+// authPlugin returns the plugin that protects a service with the given authentication, nil for
+// a public service
+func authPlugin(config *tomlConfig, name string, auth string) *kong.Plugin {
+	switch auth {
+	case NoAuth:
+		return nil
+	case JWTPlugin:
+		return jwtPlugin(config)
+	case KeyAuthPlugin:
+		s, _ := configService(config, name)
+		keyNames := s.KeyNames
+		if len(keyNames) == 0 {
+			keyNames = defaultKeyNames
+		}
+		return &kong.Plugin{Name: KeyAuthPlugin, Config: map[string]interface{}{"key_names": keyNames}}
+	case BasicAuthPlugin:
+		// the upstream services never get to see the passwords
+		return &kong.Plugin{Name: BasicAuthPlugin, Config: map[string]interface{}{"hide_credentials": true}}
+	case OAuth2Plugin:
+		return oauth2Plugin(config)
+	case LDAPAuthPlugin:
+		return ldapPlugin(config)
+	}
+	return &kong.Plugin{Name: auth}
+}
+
+func initAuthForService(config *tomlConfig, kc *kong.Client, name string, auth string) error {
+	params := authPlugin(config, name, auth)
+	if params == nil {
+		lc.Info(fmt.Sprintf("Service %s is public, no authentication is set up.", name))
+		return nil
+	}
+
+	_, err := kc.CreateServicePlugin(name, params)
+	if err != nil && !kong.IsConflict(err) {
+		s := fmt.Sprintf("Failed to set up %s authentication for service %s with error %s.", auth, name, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	lc.Info(fmt.Sprintf("Successful to set up %s authentication for service %s.", auth, name))
+	return nil
+}
+
+// requiredAuthentications returns the authentication types of the services a consumer with
+// the given roles may call, every service when no role is given. The directory holds the
+// credentials of the ldap-auth services, so they need none.
+func requiredAuthentications(config *tomlConfig, roles []string) []string {
+	services := []string{}
+	for _, s := range config.EdgexServices {
+		services = append(services, s.Name)
+	}
+	services = append(services, AdminService)
+
+	auths := []string{}
+	for _, name := range services {
+		auth := authentication(config, name)
+		if auth == NoAuth || auth == LDAPAuthPlugin || containsString(auths, auth) {
+			continue
+		}
+		if len(roles) > 0 && !rolesAllow(config, roles, name) {
+			continue
+		}
+		auths = append(auths, auth)
+	}
+	sort.Strings(auths)
+	return auths
+}
+
+func rolesAllow(config *tomlConfig, roles []string, service string) bool {
+	for _, g := range aclGroups(config, service) {
+		if containsString(roles, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// consumerCredentials holds what was provisioned for a consumer, secrets included, to be handed
+// to the user once
+type consumerCredentials struct {
+	JWT       *issuedJWT
+	KeyAuth   *kong.KeyAuthCredential
+	BasicAuth *kong.BasicAuthCredential
+	HMACAuth  *kong.HMACAuthCredential
+	OAuth2    *kong.OAuth2Credential
+}
+
+// accountOptions carries what the credentials of a new consumer are created with
+type accountOptions struct {
+	Token       tokenOptions
+	Password    string
+	KeyStore    *apiKeyStore
+	RedirectURI string
+}
+
+// provisionCredentials creates one credential of every given authentication type, see
+// requiredAuthentications. A basic-auth credential gets the password given, or a generated
+// one when it is empty.
+func provisionCredentials(kc *kong.Client, user string, auths []string, opts accountOptions) (*consumerCredentials, error) {
+	creds := &consumerCredentials{}
+	password := opts.Password
+	for _, auth := range auths {
+		var err error
+		switch auth {
+		case JWTPlugin:
+			creds.JWT, err = createJWTForConsumer(user, kc, EdgeXService, opts.Token)
+		case KeyAuthPlugin:
+			creds.KeyAuth, err = createAPIKey(kc, user, opts.KeyStore)
+		case BasicAuthPlugin:
+			if password == "" {
+				if password, err = randomSecret(); err != nil {
+					return creds, err
+				}
+			}
+			creds.BasicAuth, err = kc.CreateBasicAuthCredential(user, &kong.BasicAuthCredential{Username: user, Password: password})
+			if err == nil {
+				// Kong only returns the hash
+				creds.BasicAuth.Password = password
+			}
+		case HMACAuthPlugin:
+			creds.HMACAuth, err = kc.CreateHMACAuthCredential(user, &kong.HMACAuthCredential{Username: user})
+		case OAuth2Plugin:
+			creds.OAuth2, err = createOAuth2App(kc, user, "", opts.RedirectURI)
+		}
+		if err != nil {
+			s := fmt.Sprintf("Failed to create %s credential for consumer %s with error %s.", auth, user, err.Error())
+			lc.Error(s)
+			return creds, errors.New(s)
+		}
+		lc.Info(fmt.Sprintf("Successful to create %s credential for consumer %s.", auth, user))
+	}
+	return creds, nil
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// outputCredentials prints the credentials, only the start of the secrets when masked
+func outputCredentials(user string, creds *consumerCredentials, keyFile string, masked bool) error {
+	if creds.KeyAuth != nil {
+		outputAPIKey(user, creds.KeyAuth.Key, masked)
+	}
+	if creds.BasicAuth != nil {
+		fmt.Println(fmt.Sprintf("The basic-auth password for user %s is: %s.", user, secretOutput(creds.BasicAuth.Password, masked)))
+	}
+	if creds.HMACAuth != nil {
+		fmt.Println(fmt.Sprintf("The hmac-auth username for user %s is %s with secret: %s.", user, creds.HMACAuth.Username, secretOutput(creds.HMACAuth.Secret, masked)))
+	}
+	if creds.OAuth2 != nil {
+		outputOAuth2App(user, creds.OAuth2, masked)
+	}
+	if creds.JWT != nil {
+		return outputJWT(user, creds.JWT, keyFile, masked)
+	}
+	return nil
+}
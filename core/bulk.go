@@ -0,0 +1,343 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+const defaultBulkWorkers = 4
+
+// bulkUser is one line of an import or export file, the csv columns are the json field names
+// and lists are comma separated within their column
+type bulkUser struct {
+	Username string   `json:"username"`
+	CustomID string   `json:"custom_id,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	Auth     []string `json:"auth,omitempty"`
+	TTL      string   `json:"ttl,omitempty"`
+}
+
+var bulkUserColumns = []string{"username", "custom_id", "roles", "groups", "auth", "ttl"}
+
+// bulkResult holds the credentials created for an imported user, or why it failed
+type bulkResult struct {
+	userCredentials
+	Error string `json:"error,omitempty"`
+}
+
+var bulkResultColumns = []string{"username", "token", "jti", "private_key", "api_key", "password", "hmac_username", "hmac_secret", "oauth2_client_id", "oauth2_client_secret", "error"}
+
+func (r bulkResult) row() []string {
+	return []string{r.Username, r.Token, r.TokenID, r.PrivateKey, r.APIKey, r.Password, r.HMACUsername, r.HMACSecret, r.OAuth2ClientID, r.OAuth2ClientSecret, r.Error}
+}
+
+// bulkFormat is csv or json as given, otherwise told by the extension of the file
+func bulkFormat(path string, format string) string {
+	if format == "csv" || format == "json" {
+		return format
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		return "csv"
+	}
+	return "json"
+}
+
+func readBulkUsers(path string, format string) ([]bulkUser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := []bulkUser{}
+	if bulkFormat(path, format) == "json" {
+		err := json.NewDecoder(f).Decode(&users)
+		return users, err
+	}
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return users, nil
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["username"]; !ok {
+		return nil, errors.New("The import file has no username column.")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	for _, record := range records[1:] {
+		users = append(users, bulkUser{
+			Username: field(record, "username"),
+			CustomID: field(record, "custom_id"),
+			Roles:    splitList(field(record, "roles")),
+			Groups:   splitList(field(record, "groups")),
+			Auth:     splitList(field(record, "auth")),
+			TTL:      field(record, "ttl"),
+		})
+	}
+	return users, nil
+}
+
+func splitList(value string) []string {
+	l := listFlags{}
+	l.Set(value)
+	return l
+}
+
+func writeBulkUsers(users []bulkUser, format string, w io.Writer) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(users)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(bulkUserColumns)
+	for _, u := range users {
+		cw.Write([]string{u.Username, u.CustomID, strings.Join(u.Roles, ","), strings.Join(u.Groups, ","), strings.Join(u.Auth, ","), u.TTL})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// runPool calls work for 0..n-1 on at most workers goroutines at a time
+func runPool(n int, workers int, work func(i int)) {
+	if workers <= 0 {
+		workers = defaultBulkWorkers
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				work(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// importUsers creates the users concurrently, the results are in the order of the users. base
+// carries the signer, key store and redirect uri shared by all users, the credentials are also
+// kept in store unless it is nil.
+func importUsers(config *tomlConfig, kc *kong.Client, users []bulkUser, workers int, base accountOptions, store *credentialStore) []bulkResult {
+	results := make([]bulkResult, len(users))
+	seen := map[string]bool{}
+	for i, u := range users {
+		results[i].Username = u.Username
+		if seen[u.Username] {
+			results[i].Error = fmt.Sprintf("User %s is listed more than once.", u.Username)
+		}
+		seen[u.Username] = true
+	}
+
+	runPool(len(users), workers, func(i int) {
+		if results[i].Error != "" {
+			return
+		}
+		err := importUser(config, kc, users[i], base, &results[i])
+		if err == nil && store != nil {
+			err = store.save(users[i].Username, results[i].userCredentials)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	})
+	return results
+}
+
+func importUser(config *tomlConfig, kc *kong.Client, u bulkUser, base accountOptions, result *bulkResult) error {
+	if err := validUsername(config, u.Username); err != nil {
+		return err
+	}
+	if err := validRoles(config, u.Roles); err != nil {
+		return err
+	}
+	auths := u.Auth
+	if len(auths) == 0 {
+		auths = requiredAuthentications(config, u.Roles)
+	}
+	for _, auth := range auths {
+		if !containsString(authPlugins, auth) || auth == LDAPAuthPlugin {
+			s := fmt.Sprintf("Unsupported authentication %s for user %s, expected jwt, key-auth, basic-auth, hmac-auth or oauth2.", auth, u.Username)
+			return errors.New(s)
+		}
+	}
+	opts := base
+	if containsString(auths, JWTPlugin) {
+		token, err := newTokenOptions(config, "", u.TTL, "", "", "", nil)
+		if err != nil {
+			return err
+		}
+		token.Signer = base.Token.Signer
+		opts.Token = token
+	}
+
+	if err := createConsumer(config, u.Username, u.CustomID, kc, EdgeXService); err != nil {
+		return err
+	}
+	if err := addConsumerGroups(config, kc, u.Username, u.Roles, u.Groups); err != nil {
+		return err
+	}
+	creds, err := provisionCredentials(kc, u.Username, auths, opts)
+	result.userCredentials = credentialsOf(u.Username, creds)
+	return err
+}
+
+// outputBulkResults writes the credentials of the imported users to a file only the owner
+// can read
+func outputBulkResults(results []bulkResult, path string, format string) error {
+	f, err := createPrivateFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if bulkFormat(path, format) == "json" {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	cw := csv.NewWriter(f)
+	cw.Write(bulkResultColumns)
+	for _, r := range results {
+		cw.Write(r.row())
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportUsers describes the consumers in Kong in the import format, with the roles and groups
+// they are in and the authentication types they hold credentials for. The consumers of the
+// trusted issuers are left out.
+func exportUsers(config *tomlConfig, kc *kong.Client, workers int) ([]bulkUser, error) {
+	consumers, err := kc.ListConsumers()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].Username < consumers[j].Username })
+	exported := []kong.Consumer{}
+	for _, c := range consumers {
+		if _, ok := config.JWKS.Issuers[c.Username]; !ok && c.Username != "" {
+			exported = append(exported, c)
+		}
+	}
+
+	users := make([]bulkUser, len(exported))
+	errs := make([]error, len(exported))
+	runPool(len(exported), workers, func(i int) {
+		users[i], errs[i] = exportUser(config, kc, exported[i])
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+func exportUser(config *tomlConfig, kc *kong.Client, c kong.Consumer) (bulkUser, error) {
+	u := bulkUser{Username: c.Username, CustomID: c.CustomID}
+	acls, err := kc.ListACLs(c.ID)
+	if err != nil {
+		return u, err
+	}
+	for _, acl := range acls {
+		if _, ok := config.Roles[acl.Group]; ok {
+			u.Roles = append(u.Roles, acl.Group)
+		} else if acl.Group != NoRoleGroup {
+			u.Groups = append(u.Groups, acl.Group)
+		}
+	}
+
+	counts := map[string]func() (int, error){
+		JWTPlugin: func() (int, error) {
+			l, err := kc.ListJWTCredentials(c.ID)
+			return len(l), err
+		},
+		KeyAuthPlugin: func() (int, error) {
+			l, err := kc.ListKeyAuthCredentials(c.ID)
+			return len(l), err
+		},
+		BasicAuthPlugin: func() (int, error) {
+			l, err := kc.ListBasicAuthCredentials(c.ID)
+			return len(l), err
+		},
+		HMACAuthPlugin: func() (int, error) {
+			l, err := kc.ListHMACAuthCredentials(c.ID)
+			return len(l), err
+		},
+		OAuth2Plugin: func() (int, error) {
+			l, err := kc.ListOAuth2Credentials(c.ID)
+			return len(l), err
+		},
+	}
+	for _, auth := range authPlugins {
+		count, ok := counts[auth]
+		if !ok {
+			continue
+		}
+		n, err := count()
+		if err != nil {
+			return u, err
+		}
+		if n > 0 {
+			u.Auth = append(u.Auth, auth)
+		}
+	}
+	return u, nil
+}
+
+func outputBulkUsers(users []bulkUser, path string, format string) error {
+	if path == "-" {
+		return writeBulkUsers(users, bulkFormat(path, format), os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeBulkUsers(users, bulkFormat(path, format), f)
+}
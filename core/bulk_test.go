@@ -0,0 +1,188 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+func TestReadBulkUsers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bulk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gateway := bulkUser{Username: "gateway", CustomID: "asset-1", Roles: []string{"admin", "devices"}, TTL: "24h"}
+	tests := []struct {
+		name    string
+		file    string
+		format  string
+		content string
+		want    []bulkUser
+	}{
+		{"csv", "users.csv", "", "username,custom_id,roles,groups,auth,ttl\ngateway,asset-1,\"admin,devices\",,,24h\n", []bulkUser{gateway}},
+		{"csv columns in any order and case", "users.csv", "", " TTL ,Roles,Username,Custom_ID\n24h,\" admin , devices\",gateway,asset-1\n", []bulkUser{gateway}},
+		{"csv without optional columns", "users.csv", "", "username\nguest\n", []bulkUser{{Username: "guest"}}},
+		{"csv given as format", "users.txt", "csv", "username,auth\nguest,\"jwt,key-auth\"\n", []bulkUser{{Username: "guest", Auth: []string{"jwt", "key-auth"}}}},
+		{"empty csv", "users.csv", "", "", []bulkUser{}},
+		{"header only", "users.csv", "", "username,roles\n", []bulkUser{}},
+		{"json", "users.json", "", `[{"username": "gateway", "custom_id": "asset-1", "roles": ["admin", "devices"], "ttl": "24h"}]`, []bulkUser{gateway}},
+		{"json by default", "users", "", `[{"username": "guest"}]`, []bulkUser{{Username: "guest"}}},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readBulkUsers(path, tt.format)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err.Error())
+			continue
+		}
+		// compared as json, where an empty list and a missing one are the same
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(tt.want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("%s: read %s, want %s", tt.name, gotJSON, wantJSON)
+		}
+	}
+
+	malformed := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"csv without username column", "users.csv", "name,roles\nguest,admin\n"},
+		{"csv with an open quote", "users.csv", "username,roles\nguest,\"admin\n"},
+		{"csv with a short row", "users.csv", "username,roles\nguest\n"},
+		{"json that is not a list", "users.json", `{"username": "guest"}`},
+		{"json cut short", "users.json", `[{"username": "guest"`},
+		{"json with a wrong type", "users.json", `[{"username": "guest", "roles": "admin"}]`},
+	}
+	for _, tt := range malformed {
+		path := filepath.Join(dir, tt.file)
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if users, err := readBulkUsers(path, ""); err == nil {
+			t.Errorf("%s: read %+v without an error", tt.name, users)
+		}
+	}
+	if _, err := readBulkUsers(filepath.Join(dir, "missing.csv"), ""); err == nil {
+		t.Error("a missing file was read without an error")
+	}
+}
+
+// bulkKong creates consumers, groups and jwt credentials, except for the consumer broken
+type bulkKong struct {
+	*httptest.Server
+	mutex     sync.Mutex
+	consumers []string
+}
+
+func startBulkKong(t *testing.T) *bulkKong {
+	k := &bulkKong{}
+	k.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k.mutex.Lock()
+		defer k.mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case r.Method == "POST" && len(parts) == 1 && parts[0] == "consumers":
+			c := kong.Consumer{}
+			json.NewDecoder(r.Body).Decode(&c)
+			if c.Username == "broken" {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"message": "An unexpected error occurred"}`))
+				return
+			}
+			k.consumers = append(k.consumers, c.Username)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(c)
+		case r.Method == "POST" && len(parts) == 3 && parts[2] == "acls":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		case r.Method == "POST" && len(parts) == 3 && parts[2] == "jwt":
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(kong.JWTCredential{ID: "jwt-" + parts[1], Key: "key-" + parts[1], Secret: "secret"})
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return k
+}
+
+func TestImportUsers(t *testing.T) {
+	proxy := startBulkKong(t)
+	defer proxy.Close()
+	kc := kong.NewClient(proxy.URL+"/", &http.Client{})
+	config := syncTestConfig()
+
+	users := []bulkUser{
+		{Username: "alice", Roles: []string{"admin"}},
+		{Username: "bob", TTL: "1h"},
+		{Username: "alice"},
+		{Username: "bad-name"},
+		{Username: "carol", Roles: []string{"unknown"}},
+		{Username: "dave", Auth: []string{"ldap-auth"}},
+		{Username: "erin", TTL: "soon"},
+		{Username: "broken"},
+	}
+	failures := map[string]string{
+		"2": "listed more than once",
+		"3": "does not match the pattern",
+		"4": "unknown",
+		"5": "Unsupported authentication ldap-auth",
+		"6": "",
+		"7": "errorcode 500",
+	}
+	for _, workers := range []int{1, 3, 0} {
+		proxy.consumers = nil
+		results := importUsers(config, kc, users, workers, accountOptions{}, nil)
+		if len(results) != len(users) {
+			t.Fatalf("%d workers: %d results for %d users", workers, len(results), len(users))
+		}
+		for i, r := range results {
+			if r.Username != users[i].Username {
+				t.Errorf("%d workers: result %d is for %s, want %s", workers, i, r.Username, users[i].Username)
+			}
+			want, failed := failures[fmt.Sprint(i)]
+			switch {
+			case failed && (r.Error == "" || !strings.Contains(r.Error, want)):
+				t.Errorf("%d workers: %s failed with %q, want %q", workers, r.Username, r.Error, want)
+			case !failed && (r.Error != "" || r.Token == "" || r.TokenID != "key-"+r.Username):
+				t.Errorf("%d workers: %s = %+v, want a token", workers, r.Username, r)
+			}
+		}
+		if len(proxy.consumers) != 2 {
+			t.Errorf("%d workers: created consumers %v, want alice and bob once", workers, proxy.consumers)
+		}
+	}
+}
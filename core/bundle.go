@@ -0,0 +1,248 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// bundleAlgorithm names the envelope of an encrypted bundle: a random AES-256-GCM key encrypts
+// the bundle and is itself encrypted to the recipient's RSA public key with OAEP and SHA-256
+const bundleAlgorithm = "RSA-OAEP-256+A256GCM"
+
+// credentialBundle is what --out hands to a user, the credentials together with what is needed
+// to use them
+type credentialBundle struct {
+	userCredentials
+	Issuer string `json:"issuer,omitempty"`
+	// Key is the key of the jwt credential the token is signed for, which Kong reads from iss
+	Key       string `json:"key,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	ProxyURL  string `json:"proxy_url"`
+}
+
+type encryptedBundle struct {
+	Algorithm    string `json:"algorithm"`
+	Recipient    string `json:"recipient"`
+	EncryptedKey []byte `json:"encrypted_key"`
+	Nonce        []byte `json:"nonce"`
+	Ciphertext   []byte `json:"ciphertext"`
+}
+
+func proxyURL(config *tomlConfig) string {
+	if config.KongURL.PublicURL != "" {
+		return config.KongURL.PublicURL
+	}
+	return fmt.Sprintf("http://%s:%s/", config.KongURL.Server, config.KongURL.ApplicationPort)
+}
+
+// newBundle takes the issuer from the configuration and reads the credential key and expiry from
+// the token, which was signed here and is not verified again
+func newBundle(config *tomlConfig, creds userCredentials) credentialBundle {
+	bundle := credentialBundle{userCredentials: creds, Issuer: config.JWT.Issuer, ProxyURL: proxyURL(config)}
+	if creds.Token == "" {
+		return bundle
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(creds.Token, claims); err != nil {
+		return bundle
+	}
+	if iss, ok := claims["iss"].(string); ok {
+		bundle.Key = iss
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		bundle.ExpiresAt = time.Unix(int64(exp), 0).UTC().Format(time.RFC3339)
+	}
+	return bundle
+}
+
+// outputCredentialBundle hands the credentials of a user over in a file instead of on stdout,
+// the private key goes to keyFile when one is given and into the bundle otherwise
+func outputCredentialBundle(config *tomlConfig, creds userCredentials, keyFile string, path string, recipientFile string) error {
+	if creds.PrivateKey != "" && keyFile != "" {
+		if err := outputPrivateKey(creds.Username, creds.PrivateKey, keyFile); err != nil {
+			return err
+		}
+		creds.PrivateKey = ""
+	}
+	if err := outputBundle(newBundle(config, creds), path, recipientFile); err != nil {
+		return err
+	}
+	fmt.Println(fmt.Sprintf("The credentials for user %s are written to %s.", creds.Username, path))
+	return nil
+}
+
+// outputBundle writes the bundle to a file only the owner can read, encrypted to the public key
+// in recipientFile unless that is empty
+func outputBundle(bundle credentialBundle, path string, recipientFile string) error {
+	raw, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	if recipientFile != "" {
+		recipient, err := readRecipient(recipientFile)
+		if err != nil {
+			return err
+		}
+		envelope, err := encryptBundle(raw, recipient)
+		if err != nil {
+			return err
+		}
+		if raw, err = json.MarshalIndent(envelope, "", "  "); err != nil {
+			return err
+		}
+	}
+
+	f, err := createPrivateFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(raw, '\n')); err != nil {
+		return err
+	}
+	lc.Info(fmt.Sprintf("Successful to write the credentials for user %s to %s.", bundle.Username, path))
+	return nil
+}
+
+// createPrivateFile creates or truncates a file only the owner can read
+func createPrivateFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// OpenFile keeps the mode of a file that already exists
+	if err := os.Chmod(path, 0600); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func readRecipient(path string) (*rsa.PublicKey, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		s := fmt.Sprintf("No PEM encoded public key found in %s.", path)
+		return nil, errors.New(s)
+	}
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := public.(*rsa.PublicKey)
+	if !ok || key.N.BitLen() < 2048 {
+		s := fmt.Sprintf("The recipient key in %s must be an RSA public key of at least 2048 bits.", path)
+		return nil, errors.New(s)
+	}
+	return key, nil
+}
+
+func recipientFingerprint(key *rsa.PublicKey) string {
+	der, _ := x509.MarshalPKIXPublicKey(key)
+	sum := sha256.Sum256(der)
+	return "SHA256:" + hex.EncodeToString(sum[:])
+}
+
+func encryptBundle(plaintext []byte, recipient *rsa.PublicKey) (*encryptedBundle, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, recipient, key, []byte(bundleAlgorithm))
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedBundle{
+		Algorithm:    bundleAlgorithm,
+		Recipient:    recipientFingerprint(recipient),
+		EncryptedKey: encryptedKey,
+		Nonce:        nonce,
+		Ciphertext:   gcm.Seal(nil, nonce, plaintext, []byte(bundleAlgorithm)),
+	}, nil
+}
+
+// decryptBundle opens an encrypted bundle with the recipient's PEM encoded RSA private key
+func decryptBundle(path string, keyFile string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	envelope := encryptedBundle{}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Algorithm != bundleAlgorithm {
+		s := fmt.Sprintf("Unsupported bundle algorithm %s, expected %s.", envelope.Algorithm, bundleAlgorithm)
+		return nil, errors.New(s)
+	}
+	pemKey, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	private, err := jwt.ParseRSAPrivateKeyFromPEM(pemKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, private, envelope.EncryptedKey, []byte(bundleAlgorithm))
+	if err != nil {
+		s := fmt.Sprintf("The bundle is not encrypted to the key in %s.", keyFile)
+		return nil, errors.New(s)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("Invalid nonce in the bundle.")
+	}
+	return gcm.Open(nil, envelope.Nonce, envelope.Ciphertext, []byte(bundleAlgorithm))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
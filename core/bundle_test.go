@@ -0,0 +1,123 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// writeRSAKeyPair writes a PEM encoded RSA key pair into dir and returns the paths of the public
+// and the private key
+func writeRSAKeyPair(t *testing.T, dir string, name string) (string, string) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := filepath.Join(dir, name+".pub")
+	if err := ioutil.WriteFile(public, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key := filepath.Join(dir, name+".pem")
+	if err := ioutil.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return public, key
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	public, private := writeRSAKeyPair(t, dir, "recipient")
+	_, other := writeRSAKeyPair(t, dir, "other")
+
+	expires := time.Now().Add(time.Hour).Unix()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": "jwt-key-1", "exp": expires}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &tomlConfig{KongURL: kongurl{Server: "kong", ApplicationPort: "8000"}, JWT: jwtconfig{Issuer: "edgexsecurity"}}
+	creds := userCredentials{Username: "technician", Token: token, PrivateKey: "PRIVATE KEY"}
+
+	path := filepath.Join(dir, "bundle.json")
+	if err := outputBundle(newBundle(config, creds), path, public); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), token) || strings.Contains(string(raw), "PRIVATE KEY") {
+		t.Fatalf("the encrypted bundle holds the plaintext: %s", raw)
+	}
+	envelope := encryptedBundle{}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Algorithm != "RSA-OAEP-256+A256GCM" || !strings.HasPrefix(envelope.Recipient, "SHA256:") {
+		t.Errorf("envelope = %s %s", envelope.Algorithm, envelope.Recipient)
+	}
+
+	plain, err := decryptBundle(path, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := credentialBundle{}
+	if err := json.Unmarshal(plain, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	want := credentialBundle{
+		userCredentials: creds,
+		Issuer:          "edgexsecurity",
+		Key:             "jwt-key-1",
+		ExpiresAt:       time.Unix(expires, 0).UTC().Format(time.RFC3339),
+		ProxyURL:        "http://kong:8000/",
+	}
+	if bundle != want {
+		t.Errorf("decrypted bundle = %+v, want %+v", bundle, want)
+	}
+
+	if _, err := decryptBundle(path, other); err == nil {
+		t.Error("the bundle was opened with a key it is not encrypted to")
+	}
+	envelope.Ciphertext[0] ^= 0xff
+	tampered, _ := json.Marshal(envelope)
+	if err := ioutil.WriteFile(path, tampered, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decryptBundle(path, private); err == nil {
+		t.Error("a tampered bundle was opened")
+	}
+}
@@ -23,32 +23,26 @@ import (
 	"net/http"
 
 	"github.com/dghubble/sling"
+	"github.com/edgexfoundry/edgexsecurity/kong"
 )
 
-func loadKongCerts(config *tomlConfig, url string, secretBaseURL string, c *http.Client) error {
+func loadKongCerts(config *tomlConfig, kc *kong.Client, secretBaseURL string, c *http.Client) error {
 	cert, key, err := getCertKeyPair(config, secretBaseURL, c)
 	if err != nil {
 		return err
 	}
-	body := &CertInfo{
+	body := &kong.Certificate{
 		Cert: cert,
 		Key:  key,
 		Snis: []string{config.SecretService.SNIS},
 	}
 	lc.Info("Trying to upload cert to proxy server.")
-	req, err := sling.New().Base(url).Post(CertificatesPath).BodyJSON(body).Request()
-	resp, err := c.Do(req)
-	if err != nil {
-		lc.Error("Failed to upload cert to proxy server with error %s", err.Error())
-		return err
-	}
-
-	if resp.StatusCode == 200 || resp.StatusCode == 201 || resp.StatusCode == 409 {
-		lc.Info("Successful to add certificate to the reverse proxy.")
-	} else {
-		s := fmt.Sprintf("Failed to add certificate with errorcode %d.", resp.StatusCode)
+	_, err = kc.CreateCertificate(body)
+	if err != nil && !kong.IsConflict(err) {
+		s := fmt.Sprintf("Failed to add certificate with error %s.", err.Error())
 		return errors.New(s)
 	}
+	lc.Info("Successful to add certificate to the reverse proxy.")
 	return nil
 }
 
@@ -61,14 +55,24 @@ func getCertKeyPair(config *tomlConfig, secretBaseURL string, c *http.Client) (s
 
 	s := sling.New().Set(VaultToken, t.Token)
 	req, err := s.New().Base(secretBaseURL).Get(config.SecretService.CertPath).Request()
+	if err != nil {
+		return "", "", err
+	}
 	resp, err := c.Do(req)
 	if err != nil {
 		errStr := fmt.Sprintf("Failed to retrieve certificate with path as %s with error %s", config.SecretService.CertPath, err.Error())
 		return "", "", errors.New(errStr)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		errStr := fmt.Sprintf("Failed to retrieve certificate with path as %s with errorcode %d", config.SecretService.CertPath, resp.StatusCode)
+		return "", "", errors.New(errStr)
+	}
 	collection := CertCollect{}
-	json.NewDecoder(resp.Body).Decode(&collection)
+	err = json.NewDecoder(resp.Body).Decode(&collection)
+	if err != nil {
+		return "", "", err
+	}
 	lc.Info(collection.Section.Cert)
 	lc.Info(fmt.Sprintf("successful on retrieving certificate from %s.", config.SecretService.CertPath))
 	return collection.Section.Cert, collection.Section.Key, nil
@@ -0,0 +1,189 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// claims the tool sets itself and that --claim can't override
+var reservedClaims = []string{"iss", "account", "exp", "nbf", "iat", "aud", "jti"}
+
+type tokenOptions struct {
+	Algorithm string
+	Signer    *transitSigner
+	TTL       time.Duration
+	NotBefore time.Time
+	Audience  string
+	ID        string
+	Claims    map[string]string
+}
+
+// claimFlags collects repeated --claim key=value flags
+type claimFlags map[string]string
+
+func (c claimFlags) String() string {
+	pairs := []string{}
+	for k, v := range c {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (c claimFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		s := fmt.Sprintf("Invalid claim %s, expected key=value.", value)
+		return errors.New(s)
+	}
+	c[parts[0]] = parts[1]
+	return nil
+}
+
+// newTokenOptions combines the [jwt] defaults with the values given for a single token. ttl and
+// nbf accept a duration such as 24h, nbf also an RFC3339 time.
+func newTokenOptions(config *tomlConfig, alg string, ttl string, nbf string, aud string, jti string, claims map[string]string) (tokenOptions, error) {
+	opts := tokenOptions{
+		Algorithm: config.JWT.Algorithm,
+		Audience:  config.JWT.Audience,
+		ID:        jti,
+		Claims:    map[string]string{},
+	}
+	if alg != "" {
+		opts.Algorithm = alg
+	}
+	if opts.Algorithm == "" {
+		opts.Algorithm = "HS256"
+	}
+	if err := validAlgorithm(opts.Algorithm); err != nil {
+		return opts, err
+	}
+	if aud != "" {
+		opts.Audience = aud
+	}
+	if jti != "" {
+		revoked, err := isRevoked(config, jti)
+		if err != nil {
+			return opts, err
+		}
+		if revoked {
+			s := fmt.Sprintf("Token id %s has been revoked and can't be issued again.", jti)
+			return opts, errors.New(s)
+		}
+	}
+
+	if ttl == "" {
+		ttl = config.JWT.TTL
+	}
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d < 0 {
+			s := fmt.Sprintf("Invalid token lifetime %s.", ttl)
+			return opts, errors.New(s)
+		}
+		opts.TTL = d
+	}
+	if opts.TTL == 0 && containsString(config.JWT.ClaimsToVerify, "exp") {
+		return opts, errors.New("Tokens without expiry are rejected by the reverse proxy when exp is in claimstoverify, please set a lifetime.")
+	}
+
+	if nbf != "" {
+		if d, err := time.ParseDuration(nbf); err == nil {
+			opts.NotBefore = time.Now().Add(d)
+		} else if t, err := time.Parse(time.RFC3339, nbf); err == nil {
+			opts.NotBefore = t
+		} else {
+			s := fmt.Sprintf("Invalid not-before %s, expected a duration or an RFC3339 time.", nbf)
+			return opts, errors.New(s)
+		}
+	} else if containsString(config.JWT.ClaimsToVerify, "nbf") {
+		// a verified claim must be present in the token
+		opts.NotBefore = time.Now()
+	}
+
+	for k, v := range config.JWT.Claims {
+		opts.Claims[k] = v
+	}
+	for k, v := range claims {
+		opts.Claims[k] = v
+	}
+	for k := range opts.Claims {
+		if containsString(reservedClaims, k) {
+			s := fmt.Sprintf("Claim %s is set by the security service and can't be overridden.", k)
+			return opts, errors.New(s)
+		}
+	}
+	return opts, nil
+}
+
+// tokenClaims builds the claims of a token for the credential key, which Kong reads from iss
+// to find the credential to verify the signature with
+func tokenClaims(user string, key string, opts tokenOptions) (jwt.MapClaims, error) {
+	now := time.Now()
+	id := opts.ID
+	if id == "" {
+		var err error
+		if id, err = newTokenID(); err != nil {
+			return nil, err
+		}
+	}
+
+	standard := KongJWTClaims{
+		ISS:  key,
+		Acct: user,
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt: now.Unix(),
+			Audience: opts.Audience,
+			Id:       id,
+		},
+	}
+	if opts.TTL > 0 {
+		standard.ExpiresAt = now.Add(opts.TTL).Unix()
+	}
+	if !opts.NotBefore.IsZero() {
+		standard.NotBefore = opts.NotBefore.Unix()
+	}
+
+	raw, err := json.Marshal(standard)
+	if err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{}
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return nil, err
+	}
+	for k, v := range opts.Claims {
+		claims[k] = v
+	}
+	return claims, nil
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -17,12 +17,11 @@
 package main
 
 const (
-	ServicesPath     = "services/"
-	RoutesPath       = "routes/"
-	ConsumersPath    = "consumers/"
-	CertificatesPath = "certificates/"
-	PluginsPath      = "plugins/"
-	SecurityService  = "securityservice"
-	EdgeXService     = "edgex"
-	VaultToken       = "X-Vault-Token"
+	SecurityService = "securityservice"
+	EdgeXService    = "edgex"
+	AdminService    = "admin"
+	JWTPlugin       = "jwt"
+	ACLPlugin       = "acl"
+	NoRoleGroup     = "edgex-no-role"
+	VaultToken      = "X-Vault-Token"
 )
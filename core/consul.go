@@ -0,0 +1,274 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/sling"
+)
+
+// sections of tomlConfig that can be overridden from Consul, keyed by their toml table name
+var consulSections = map[string]string{
+	"kongurl":       "KongURL",
+	"secretservice": "SecretService",
+	"edgexservices": "EdgexServices",
+}
+
+type consulKV struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"`
+}
+
+// loadConsulConfig overlays the values stored in Consul under the configured prefix on top of
+// the ones read from the local file, e.g. <prefix>/kongurl/server or
+// <prefix>/edgexservices/coredata/host. When nothing is stored yet and seeding is enabled, the
+// local values are written to Consul instead.
+func loadConsulConfig(config *tomlConfig, c *http.Client) error {
+	baseURL := fmt.Sprintf("http://%s:%s/v1/kv/", config.Consul.Server, config.Consul.Port)
+	prefix := strings.Trim(config.Consul.Prefix, "/")
+
+	kv, err := getConsulKV(config, baseURL, prefix, c)
+	if err != nil {
+		return err
+	}
+	if len(kv) == 0 {
+		if !config.Consul.Seed {
+			lc.Info(fmt.Sprintf("No configuration found in Consul under %s, using the local configuration.", prefix))
+			return nil
+		}
+		return seedConsul(config, baseURL, prefix, c)
+	}
+
+	keys := []string{}
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		err := setConfigValue(config, strings.TrimPrefix(k, prefix+"/"), kv[k])
+		if err != nil {
+			return err
+		}
+	}
+	lc.Info(fmt.Sprintf("Successful to load %d configuration values from Consul under %s.", len(kv), prefix))
+	return nil
+}
+
+func getConsulKV(config *tomlConfig, baseURL string, prefix string, c *http.Client) (map[string]string, error) {
+	req, err := sling.New().Base(baseURL).Get(prefix+"/?recurse=true").Set("X-Consul-Token", config.Consul.Token).Request()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		s := fmt.Sprintf("Failed to retrieve configuration from Consul with error %s.", err.Error())
+		return nil, errors.New(s)
+	}
+	defer resp.Body.Close()
+
+	kv := map[string]string{}
+	if resp.StatusCode == 404 {
+		return kv, nil
+	}
+	if resp.StatusCode != 200 {
+		s := fmt.Sprintf("Failed to retrieve configuration from Consul with errorcode %d.", resp.StatusCode)
+		return nil, errors.New(s)
+	}
+
+	pairs := []consulKV{}
+	err = json.NewDecoder(resp.Body).Decode(&pairs)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pairs {
+		// folders come back as keys ending with / and without a value
+		if strings.HasSuffix(p.Key, "/") {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(p.Value)
+		if err != nil {
+			return nil, err
+		}
+		kv[p.Key] = string(value)
+	}
+	return kv, nil
+}
+
+func seedConsul(config *tomlConfig, baseURL string, prefix string, c *http.Client) error {
+	values, err := flattenConfig(config)
+	if err != nil {
+		return err
+	}
+	keys := []string{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		req, err := sling.New().Base(baseURL).Put(prefix+"/"+k).Set("X-Consul-Token", config.Consul.Token).Body(strings.NewReader(values[k])).Request()
+		if err != nil {
+			return err
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			s := fmt.Sprintf("Failed to seed %s to Consul with error %s.", k, err.Error())
+			return errors.New(s)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			s := fmt.Sprintf("Failed to seed %s to Consul with errorcode %d.", k, resp.StatusCode)
+			return errors.New(s)
+		}
+	}
+	lc.Info(fmt.Sprintf("Successful to seed %d configuration values to Consul under %s.", len(keys), prefix))
+	return nil
+}
+
+func flattenConfig(config *tomlConfig) (map[string]string, error) {
+	values := map[string]string{}
+	v := reflect.ValueOf(config).Elem()
+	for section, field := range consulSections {
+		f := v.FieldByName(field)
+		if f.Kind() == reflect.Map {
+			for _, name := range f.MapKeys() {
+				err := flattenStruct(values, section+"/"+name.String(), f.MapIndex(name))
+				if err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		err := flattenStruct(values, section, f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// flattenStruct writes the fields of a section the way setStructField reads them back, lists
+// of strings comma separated
+func flattenStruct(values map[string]string, prefix string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := prefix + "/" + strings.ToLower(t.Field(i).Name)
+		f := v.Field(i)
+		switch {
+		case f.Kind() == reflect.String, f.Kind() == reflect.Int, f.Kind() == reflect.Bool, f.Kind() == reflect.Float64:
+			values[key] = fmt.Sprint(f.Interface())
+		case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+			values[key] = strings.Join(f.Interface().([]string), ",")
+		default:
+			s := fmt.Sprintf("Configuration key %s of type %s can't be stored in Consul.", key, f.Type())
+			return errors.New(s)
+		}
+	}
+	return nil
+}
+
+func setConfigValue(config *tomlConfig, key string, value string) error {
+	parts := strings.Split(key, "/")
+	field, ok := consulSections[parts[0]]
+	if !ok {
+		lc.Info(fmt.Sprintf("Ignoring unknown configuration key %s from Consul.", key))
+		return nil
+	}
+
+	section := reflect.ValueOf(config).Elem().FieldByName(field)
+	if section.Kind() != reflect.Map {
+		if len(parts) != 2 {
+			s := fmt.Sprintf("Invalid configuration key %s from Consul.", key)
+			return errors.New(s)
+		}
+		return setStructField(section, parts[1], value, key)
+	}
+
+	if len(parts) != 3 {
+		s := fmt.Sprintf("Invalid configuration key %s from Consul.", key)
+		return errors.New(s)
+	}
+	if section.IsNil() {
+		section.Set(reflect.MakeMap(section.Type()))
+	}
+	name := reflect.ValueOf(parts[1])
+	entry := reflect.New(section.Type().Elem()).Elem()
+	if current := section.MapIndex(name); current.IsValid() {
+		entry.Set(current)
+	}
+	err := setStructField(entry, parts[2], value, key)
+	if err != nil {
+		return err
+	}
+	section.SetMapIndex(name, entry)
+	return nil
+}
+
+func setStructField(v reflect.Value, name string, value string, key string) error {
+	f := v.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+	if !f.IsValid() {
+		lc.Info(fmt.Sprintf("Ignoring unknown configuration key %s from Consul.", key))
+		return nil
+	}
+
+	var err error
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Int:
+		var i int
+		i, err = strconv.Atoi(value)
+		f.SetInt(int64(i))
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(value)
+		f.SetBool(b)
+	case reflect.Float64:
+		var fl float64
+		fl, err = strconv.ParseFloat(value, 64)
+		f.SetFloat(fl)
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			s := fmt.Sprintf("Configuration key %s of type %s can't be read from Consul.", key, f.Type())
+			return errors.New(s)
+		}
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.Set(reflect.ValueOf(items))
+	default:
+		s := fmt.Sprintf("Configuration key %s of type %s can't be read from Consul.", key, f.Type())
+		return errors.New(s)
+	}
+	if err != nil {
+		s := fmt.Sprintf("Invalid value %s for configuration key %s from Consul.", value, key)
+		return errors.New(s)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// consulStandIn keeps a key/value store behind the parts of the Consul KV API that are used here
+type consulStandIn struct {
+	*httptest.Server
+	mutex sync.Mutex
+	store map[string]string
+}
+
+func startConsul(store map[string]string) *consulStandIn {
+	c := &consulStandIn{store: store}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		switch r.Method {
+		case "PUT":
+			value, _ := ioutil.ReadAll(r.Body)
+			c.store[key] = string(value)
+			w.Write([]byte("true"))
+		case "GET":
+			pairs := []consulKV{}
+			for k, v := range c.store {
+				if strings.HasPrefix(k, key) {
+					pairs = append(pairs, consulKV{Key: k, Value: base64.StdEncoding.EncodeToString([]byte(v))})
+				}
+			}
+			if len(pairs) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(pairs)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	return c
+}
+
+func consulTestConfig(server *httptest.Server, seed bool) *tomlConfig {
+	u, _ := url.Parse(server.URL)
+	config := syncTestConfig()
+	config.Consul = consul{Server: u.Hostname(), Port: u.Port(), Prefix: "edgex/security/proxy", Seed: seed}
+	return config
+}
+
+func TestConsulSeedRoundTrip(t *testing.T) {
+	server := startConsul(map[string]string{})
+	defer server.Close()
+
+	seeded := consulTestConfig(server.Server, true)
+	seeded.EdgexServices["coredata"] = service{Name: "coredata", Host: "edgex-core-data", Port: "48080", KeyNames: []string{"apikey", "x-api-key"}}
+	if err := loadConsulConfig(seeded, &http.Client{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.store["edgex/security/proxy/edgexservices/coredata/keynames"]; got != "apikey,x-api-key" {
+		t.Errorf("seeded keynames = %q, want apikey,x-api-key", got)
+	}
+
+	loaded := consulTestConfig(server.Server, false)
+	loaded.EdgexServices = map[string]service{}
+	if err := loadConsulConfig(loaded, &http.Client{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.EdgexServices, seeded.EdgexServices) {
+		t.Errorf("services read back = %+v, want %+v", loaded.EdgexServices, seeded.EdgexServices)
+	}
+	if !reflect.DeepEqual(loaded.KongURL, seeded.KongURL) || !reflect.DeepEqual(loaded.SecretService, seeded.SecretService) {
+		t.Errorf("sections read back differ from the seeded ones")
+	}
+}
+
+func TestConsulOverrides(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		check func(config *tomlConfig) bool
+	}{
+		{"kongurl/server", "kong.local", func(c *tomlConfig) bool { return c.KongURL.Server == "kong.local" }},
+		{"kongurl/pagesize", "250", func(c *tomlConfig) bool { return c.KongURL.PageSize == 250 }},
+		{"secretservice/standbyok", "true", func(c *tomlConfig) bool { return c.SecretService.StandbyOK }},
+		{"edgexservices/coredata/host", "remote", func(c *tomlConfig) bool { return c.EdgexServices["coredata"].Host == "remote" }},
+		{"edgexservices/coredata/keynames", "apikey, x-api-key", func(c *tomlConfig) bool {
+			return reflect.DeepEqual(c.EdgexServices["coredata"].KeyNames, []string{"apikey", "x-api-key"})
+		}},
+		{"edgexservices/coredata/keynames", "", func(c *tomlConfig) bool { return len(c.EdgexServices["coredata"].KeyNames) == 0 }},
+		{"edgexservices/metadata/name", "metadata", func(c *tomlConfig) bool { return c.EdgexServices["metadata"].Name == "metadata" }},
+		{"unknown/key", "ignored", func(c *tomlConfig) bool { return true }},
+	}
+	for _, tt := range tests {
+		server := startConsul(map[string]string{"edgex/security/proxy/" + tt.key: tt.value})
+		config := consulTestConfig(server.Server, false)
+		err := loadConsulConfig(config, &http.Client{})
+		server.Close()
+		if err != nil {
+			t.Errorf("%s=%s: %s", tt.key, tt.value, err.Error())
+			continue
+		}
+		if !tt.check(config) {
+			t.Errorf("%s=%s was not applied", tt.key, tt.value)
+		}
+	}
+}
+
+func TestConsulInvalidValues(t *testing.T) {
+	for _, key := range []string{"kongurl/pagesize", "secretservice/standbyok", "kongurl", "edgexservices/coredata"} {
+		server := startConsul(map[string]string{"edgex/security/proxy/" + key: "not-a-value"})
+		config := consulTestConfig(server.Server, false)
+		err := loadConsulConfig(config, &http.Client{})
+		server.Close()
+		if err == nil {
+			t.Errorf("%s=not-a-value was accepted", key)
+		}
+	}
+}
+
+func TestSetStructFieldUnsupportedKind(t *testing.T) {
+	v := struct {
+		Ports []int
+		Extra map[string]string
+	}{}
+	for _, name := range []string{"ports", "extra"} {
+		if err := setStructField(reflect.ValueOf(&v).Elem(), name, "1", "test/"+name); err == nil {
+			t.Errorf("%s was set without an error", name)
+		}
+	}
+	values := map[string]string{}
+	if err := flattenStruct(values, "test", reflect.ValueOf(v)); err == nil {
+		t.Errorf("flattenStruct stored %v without an error", values)
+	}
+}
@@ -0,0 +1,264 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+// userCredentials are the secrets handed to a user, as written by --import and kept in the
+// secret service with [secretservice] storecredentials
+type userCredentials struct {
+	Username           string `json:"username"`
+	Token              string `json:"token,omitempty"`
+	TokenID            string `json:"jti,omitempty"`
+	PrivateKey         string `json:"private_key,omitempty"`
+	APIKey             string `json:"api_key,omitempty"`
+	Password           string `json:"password,omitempty"`
+	HMACUsername       string `json:"hmac_username,omitempty"`
+	HMACSecret         string `json:"hmac_secret,omitempty"`
+	OAuth2ClientID     string `json:"oauth2_client_id,omitempty"`
+	OAuth2ClientSecret string `json:"oauth2_client_secret,omitempty"`
+	UpdatedAt          string `json:"updated_at,omitempty"`
+}
+
+func credentialsOf(user string, creds *consumerCredentials) userCredentials {
+	u := userCredentials{Username: user}
+	if creds == nil {
+		return u
+	}
+	if creds.JWT != nil {
+		u = jwtCredentialsOf(user, creds.JWT)
+	}
+	if creds.KeyAuth != nil {
+		u.APIKey = creds.KeyAuth.Key
+	}
+	if creds.BasicAuth != nil {
+		u.Password = creds.BasicAuth.Password
+	}
+	if creds.HMACAuth != nil {
+		u.HMACUsername = creds.HMACAuth.Username
+		u.HMACSecret = creds.HMACAuth.Secret
+	}
+	if creds.OAuth2 != nil {
+		u.OAuth2ClientID = creds.OAuth2.ClientID
+		u.OAuth2ClientSecret = creds.OAuth2.ClientSecret
+	}
+	return u
+}
+
+func jwtCredentialsOf(user string, issued *issuedJWT) userCredentials {
+	u := userCredentials{Username: user, Token: issued.Token, PrivateKey: issued.PrivateKey}
+	if issued.Credential != nil {
+		u.TokenID = issued.Credential.Key
+	}
+	return u
+}
+
+// credentialStore keeps the current credentials of every consumer in the vault kv secret
+// <credentialpath><username>. The credentials issued later replace the stored ones of the same
+// kind, the others are kept.
+type credentialStore struct {
+	kv   *vaultKV
+	path string
+}
+
+// newCredentialStore returns nil when the credentials are not to be stored in the secret service
+func newCredentialStore(config *tomlConfig, secretBaseURL string, c *http.Client) (*credentialStore, error) {
+	if !config.SecretService.StoreCredentials {
+		return nil, nil
+	}
+	kv, err := newVaultKV(config, secretBaseURL, c)
+	if err != nil {
+		return nil, err
+	}
+	return &credentialStore{kv: kv, path: config.SecretService.CredentialPath}, nil
+}
+
+// read returns nil when nothing is stored for the user
+func (s *credentialStore) read(user string) (*userCredentials, error) {
+	stored := &userCredentials{}
+	path, err := kvPath(s.path, user)
+	if err != nil {
+		return nil, err
+	}
+	found, err := s.kv.read(path, stored)
+	if err != nil || !found {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (s *credentialStore) save(user string, issued userCredentials) error {
+	stored, err := s.read(user)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		stored = &userCredentials{}
+	}
+	if issued.Token != "" {
+		// the private key belongs to the token it was issued with
+		stored.Token, stored.TokenID, stored.PrivateKey = issued.Token, issued.TokenID, issued.PrivateKey
+	}
+	if issued.APIKey != "" {
+		stored.APIKey = issued.APIKey
+	}
+	if issued.Password != "" {
+		stored.Password = issued.Password
+	}
+	if issued.HMACSecret != "" {
+		stored.HMACUsername, stored.HMACSecret = issued.HMACUsername, issued.HMACSecret
+	}
+	if issued.OAuth2ClientSecret != "" {
+		stored.OAuth2ClientID, stored.OAuth2ClientSecret = issued.OAuth2ClientID, issued.OAuth2ClientSecret
+	}
+	stored.Username = user
+	stored.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	path, err := kvPath(s.path, user)
+	if err != nil {
+		return err
+	}
+	if err := s.kv.write(path, stored); err != nil {
+		return err
+	}
+	lc.Info(fmt.Sprintf("Successful to store the credentials of consumer %s in the secret service.", user))
+	return nil
+}
+
+// clear removes the stored credentials of a deleted consumer
+func (s *credentialStore) clear(user string) error {
+	path, err := kvPath(s.path, user)
+	if err != nil {
+		return err
+	}
+	return s.kv.remove(path)
+}
+
+// getStoredToken returns the current token of the user from the secret service, a revoked
+// token is refused
+func getStoredToken(config *tomlConfig, store *credentialStore, user string) (*userCredentials, error) {
+	if store == nil {
+		return nil, errors.New("The credentials are not stored in the secret service, see [secretservice] storecredentials.")
+	}
+	stored, err := store.read(user)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.Token == "" {
+		s := fmt.Sprintf("No token of consumer %s is stored in the secret service.", user)
+		return nil, errors.New(s)
+	}
+	revoked, err := isRevoked(config, stored.TokenID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		s := fmt.Sprintf("The stored token of consumer %s has been revoked.", user)
+		return nil, errors.New(s)
+	}
+	return stored, nil
+}
+
+func writeStoredToken(stored *userCredentials, format string, w io.Writer) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stored)
+	}
+	_, err := fmt.Fprintln(w, stored.Token)
+	return err
+}
+
+// secretOutput returns what is printed of a secret, only its start once it is kept in the
+// secret service so that it does not end up in container logs
+func secretOutput(secret string, masked bool) string {
+	if masked {
+		return maskKey(secret)
+	}
+	return secret
+}
+
+// saveOrRollback stores the credentials just created for user, and when that fails deletes them
+// from the reverse proxy again, as nobody could read them back. What can't be deleted is listed
+// by id so that it can be revoked by hand.
+func saveOrRollback(store *credentialStore, kc *kong.Client, user string, creds *consumerCredentials, keyStore *apiKeyStore) error {
+	err := store.save(user, credentialsOf(user, creds))
+	if err == nil {
+		return nil
+	}
+	lc.Error(fmt.Sprintf("Failed to store the credentials of consumer %s, removing them again.", user))
+	if err := removeCredentials(kc, user, creds, keyStore); err != nil {
+		return err
+	}
+	lc.Info(fmt.Sprintf("Successful to remove the credentials of consumer %s that could not be stored.", user))
+	return err
+}
+
+// removeCredentials deletes the credentials in creds from the reverse proxy, e.g. what was
+// created before provisioning failed. What can't be deleted is listed in the error.
+func removeCredentials(kc *kong.Client, user string, creds *consumerCredentials, keyStore *apiKeyStore) error {
+	left := []string{}
+	remove := func(kind string, id string, del func() error) {
+		// a create that failed leaves an empty credential behind
+		if id == "" {
+			return
+		}
+		err := del()
+		if _, gone := err.(credentialNotFound); gone || kong.IsNotFound(err) {
+			return
+		}
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to remove %s credential %s of consumer %s with error %s.", kind, id, user, err.Error()))
+			left = append(left, fmt.Sprintf("%s %s", kind, id))
+		}
+	}
+	if creds.JWT != nil && creds.JWT.Credential != nil {
+		id := creds.JWT.Credential.ID
+		remove(JWTPlugin, id, func() error { return kc.DeleteJWTCredential(user, id) })
+	}
+	if creds.KeyAuth != nil {
+		id := creds.KeyAuth.ID
+		remove(KeyAuthPlugin, id, func() error { return revokeAPIKey(kc, user, id, keyStore) })
+	}
+	if creds.BasicAuth != nil {
+		id := creds.BasicAuth.ID
+		remove(BasicAuthPlugin, id, func() error { return kc.DeleteBasicAuthCredential(user, id) })
+	}
+	if creds.HMACAuth != nil {
+		id := creds.HMACAuth.ID
+		remove(HMACAuthPlugin, id, func() error { return kc.DeleteHMACAuthCredential(user, id) })
+	}
+	if creds.OAuth2 != nil {
+		id := creds.OAuth2.ID
+		remove(OAuth2Plugin, id, func() error { return kc.DeleteOAuth2Credential(user, id) })
+	}
+	if len(left) > 0 {
+		s := fmt.Sprintf("The credentials %s of consumer %s are live but not stored anywhere, please revoke them or rotate the consumer.", strings.Join(left, ", "), user)
+		lc.Error(s)
+		return errors.New(s)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+func TestSaveOrRollback(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer vault.Close()
+
+	var mutex sync.Mutex
+	deleted := []string{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch {
+		case r.Method == "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/consumers/guest/key-auth/":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":[{"id":"key-id","key":"secret-key"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer proxy.Close()
+
+	store := &credentialStore{kv: &vaultKV{client: &http.Client{}, baseURL: vault.URL + "/", token: "root"}, path: "v1/secret/edgex/users"}
+	kc := kong.NewClient(proxy.URL+"/", &http.Client{})
+	creds := &consumerCredentials{
+		JWT:     &issuedJWT{Token: "token", Credential: &kong.JWTCredential{ID: "jwt-id", Key: "jti"}},
+		KeyAuth: &kong.KeyAuthCredential{ID: "key-id", Key: "secret-key"},
+		OAuth2:  &kong.OAuth2Credential{ID: "app-id", ClientID: "client", ClientSecret: "secret"},
+	}
+	if err := saveOrRollback(store, kc, "guest", creds, nil); err == nil {
+		t.Fatal("expected the failed save to be reported")
+	}
+	sort.Strings(deleted)
+	want := []string{"/consumers/guest/jwt/jwt-id", "/consumers/guest/key-auth/key-id", "/consumers/guest/oauth2/app-id"}
+	if !reflect.DeepEqual(deleted, want) {
+		t.Errorf("deleted %q, want %q", deleted, want)
+	}
+}
+
+func TestSaveOrRollbackAlreadyRemoved(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer vault.Close()
+	// every credential is gone already, the api key no longer shows up in the list of the consumer
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/consumers/guest/key-auth/" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":[]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer proxy.Close()
+
+	store := &credentialStore{kv: &vaultKV{client: &http.Client{}, baseURL: vault.URL + "/", token: "root"}, path: "v1/secret/edgex/users"}
+	kc := kong.NewClient(proxy.URL+"/", &http.Client{})
+	creds := &consumerCredentials{
+		JWT:     &issuedJWT{Token: "token", Credential: &kong.JWTCredential{ID: "jwt-id", Key: "jti"}},
+		KeyAuth: &kong.KeyAuthCredential{ID: "key-id", Key: "secret-key"},
+	}
+	err := saveOrRollback(store, kc, "guest", creds, nil)
+	if err == nil {
+		t.Fatal("expected the failed save to be reported")
+	}
+	if strings.Contains(err.Error(), "are live") {
+		t.Errorf("credentials that are gone already are reported as left behind: %s", err.Error())
+	}
+}
+
+func TestRemoveCredentialsAfterFailedProvisioning(t *testing.T) {
+	deleted := []string{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer proxy.Close()
+
+	// the jwt was created, the basic-auth credential that followed failed
+	creds := &consumerCredentials{
+		JWT:       &issuedJWT{Token: "token", Credential: &kong.JWTCredential{ID: "jwt-id", Key: "jti"}},
+		BasicAuth: &kong.BasicAuthCredential{},
+	}
+	if err := removeCredentials(kong.NewClient(proxy.URL+"/", &http.Client{}), "guest", creds, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/consumers/guest/jwt/jwt-id"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("deleted %q, want %q", deleted, want)
+	}
+}
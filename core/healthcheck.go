@@ -17,41 +17,88 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 
 	"github.com/dghubble/sling"
+	"github.com/edgexfoundry/edgexsecurity/kong"
 )
 
-func checkProxyStatus(url string, c *http.Client) {
-	req, err := sling.New().Get(url).Request()
-	resp, err := c.Do(req)
+func checkProxyStatus(kc *kong.Client) error {
+	err := kc.Status()
 	if err != nil {
-		lc.Error(fmt.Sprintf("The status of reverse proxy is unknown with error %s, the initialization is terminated.", err.Error()))
-		os.Exit(0)
-	} else {
-		if resp.StatusCode == 200 {
-			lc.Info("Reverse proxy is up successfully.")
-		} else {
-			lc.Error(fmt.Sprintf("The status of reverse proxy is unknown with error code %d, the initialization is terminated.", resp.StatusCode))
-			os.Exit(0)
-		}
+		s := fmt.Sprintf("The status of reverse proxy is unknown with error %s.", err.Error())
+		return errors.New(s)
+	}
+	lc.Info("Reverse proxy is up successfully.")
+	return nil
+}
+
+type vaultHealth struct {
+	Initialized        bool   `json:"initialized"`
+	Sealed             bool   `json:"sealed"`
+	Standby            bool   `json:"standby"`
+	PerformanceStandby bool   `json:"performance_standby"`
+	ReplicationDRMode  string `json:"replication_dr_mode"`
+	Version            string `json:"version"`
+}
+
+// state names what v1/sys/health reports; Vault signals it both in the body and in the status
+// code, 429 standby, 472 DR secondary, 473 performance standby, 501 uninitialized, 503 sealed
+func (h vaultHealth) state(code int) string {
+	switch {
+	case code == 501 || !h.Initialized:
+		return "uninitialized"
+	case code == 503 || h.Sealed:
+		return "sealed"
+	case code == 472:
+		return "dr secondary"
+	case code == 473 || h.PerformanceStandby:
+		return "performance standby"
+	case code == 429 || h.Standby:
+		return "standby"
+	case code == 200:
+		return "active"
 	}
+	return fmt.Sprintf("unknown (errorcode %d)", code)
 }
 
-func checkSecretServiceStatus(url string, c *http.Client) {
+func checkSecretServiceStatus(url string, c *http.Client, standbyOK bool) error {
 	req, err := sling.New().Get(url).Request()
+	if err != nil {
+		return err
+	}
 	resp, err := c.Do(req)
 	if err != nil {
-		lc.Error("The status of secret service is unknown, the initialization is terminated.")
-		os.Exit(0)
-	} else {
-		if resp.StatusCode == 200 {
-			lc.Info("Secret management service is up successfully.")
-		} else {
-			lc.Error(fmt.Sprintf("Secret management service is down. Please check the status of secret service with endpoint %s.", url))
-			os.Exit(0)
+		s := fmt.Sprintf("The status of secret service is unknown with error %s.", err.Error())
+		return errors.New(s)
+	}
+	defer resp.Body.Close()
+
+	health := vaultHealth{}
+	err = json.NewDecoder(resp.Body).Decode(&health)
+	if err != nil {
+		s := fmt.Sprintf("The status of secret service is unknown with errorcode %d. Please check the status of secret service with endpoint %s.", resp.StatusCode, url)
+		return errors.New(s)
+	}
+
+	state := health.state(resp.StatusCode)
+	switch state {
+	case "active":
+	case "standby", "performance standby":
+		if !standbyOK {
+			s := fmt.Sprintf("Secret management service is a %s node and standby nodes are not accepted.", state)
+			return errors.New(s)
 		}
+	case "sealed":
+		s := "Secret management service is sealed, waiting for it to be unsealed."
+		return errors.New(s)
+	default:
+		s := fmt.Sprintf("Secret management service is %s. Please check the status of secret service with endpoint %s.", state, url)
+		return errors.New(s)
 	}
+	lc.Info(fmt.Sprintf("Secret management service %s is up successfully as %s node.", health.Version, state))
+	return nil
 }
@@ -0,0 +1,92 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVaultHealthState(t *testing.T) {
+	active := vaultHealth{Initialized: true}
+	tests := []struct {
+		code   int
+		health vaultHealth
+		want   string
+	}{
+		{200, active, "active"},
+		{429, vaultHealth{Initialized: true, Standby: true}, "standby"},
+		{429, active, "standby"},
+		{472, vaultHealth{Initialized: true, ReplicationDRMode: "secondary"}, "dr secondary"},
+		{473, vaultHealth{Initialized: true, Standby: true, PerformanceStandby: true}, "performance standby"},
+		{473, active, "performance standby"},
+		{501, vaultHealth{}, "uninitialized"},
+		{501, active, "uninitialized"},
+		{503, vaultHealth{Initialized: true, Sealed: true}, "sealed"},
+		{503, active, "sealed"},
+		// with ?standbyok=true and the like vault answers 200 and only the body tells
+		{200, vaultHealth{}, "uninitialized"},
+		{200, vaultHealth{Initialized: true, Sealed: true}, "sealed"},
+		{200, vaultHealth{Initialized: true, Standby: true}, "standby"},
+		{200, vaultHealth{Initialized: true, PerformanceStandby: true}, "performance standby"},
+		{500, active, "unknown (errorcode 500)"},
+	}
+	for _, tt := range tests {
+		if got := tt.health.state(tt.code); got != tt.want {
+			t.Errorf("state(%d) of %+v = %q, want %q", tt.code, tt.health, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSecretServiceStatus(t *testing.T) {
+	tests := []struct {
+		code      int
+		health    vaultHealth
+		standbyOK bool
+		ok        bool
+	}{
+		{200, vaultHealth{Initialized: true}, false, true},
+		{429, vaultHealth{Initialized: true, Standby: true}, false, false},
+		{429, vaultHealth{Initialized: true, Standby: true}, true, true},
+		{473, vaultHealth{Initialized: true, PerformanceStandby: true}, true, true},
+		{472, vaultHealth{Initialized: true}, true, false},
+		{501, vaultHealth{}, true, false},
+		{503, vaultHealth{Initialized: true, Sealed: true}, true, false},
+	}
+	for _, tt := range tests {
+		vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.code)
+			json.NewEncoder(w).Encode(tt.health)
+		}))
+		err := checkSecretServiceStatus(vault.URL+"/v1/sys/health", &http.Client{}, tt.standbyOK)
+		vault.Close()
+		if (err == nil) != tt.ok {
+			t.Errorf("vault answering %d with %+v, standbyok %v: %v, want ok %v", tt.code, tt.health, tt.standbyOK, err, tt.ok)
+		}
+	}
+
+	garbled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>bad gateway</html>"))
+	}))
+	defer garbled.Close()
+	if err := checkSecretServiceStatus(garbled.URL, &http.Client{}, true); err == nil {
+		t.Error("an answer that is not vault health was accepted")
+	}
+}
@@ -17,129 +17,139 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
-	"github.com/dghubble/sling"
+	"github.com/edgexfoundry/edgexsecurity/kong"
 )
 
-func initSecurityServices(config *tomlConfig, baseURL string, secretBaseURL string, client *http.Client) {
+// initSecurityServices sets up as much of the reverse proxy as it can and returns an error
+// when any part of it failed
+func initSecurityServices(config *tomlConfig, kc *kong.Client, secretBaseURL string, client *http.Client) error {
+	if err := validAuthentication(config); err != nil {
+		lc.Error(err.Error())
+		return err
+	}
+	failed := 0
+	count := func(err error) {
+		if err != nil {
+			failed++
+		}
+	}
 	for _, service := range config.EdgexServices {
-		serviceParams := &KongService{
-			Name:     service.Name,
-			Host:     service.Host,
-			Port:     service.Port,
-			Protocol: service.Protocol,
+		serviceParams, err := kongServiceFromConfig(service.Name, service.Host, service.Port, service.Protocol)
+		if err != nil {
+			lc.Error(err.Error())
+			failed++
+			continue
 		}
 
-		initKongService(baseURL, client, serviceParams)
-		jwtServicePath := fmt.Sprintf("%s%s/%s", ServicesPath, service.Name, PluginsPath)
-		initJWTAuthForService(baseURL, client, jwtServicePath, service.Name)
+		count(initKongService(kc, serviceParams))
+		count(initAuthForService(config, kc, service.Name, authentication(config, service.Name)))
+		count(initACLForService(config, kc, service.Name))
 	}
 
 	for _, service := range config.EdgexServices {
-		routeParams := &KongRoute{
+		routeParams := &kong.Route{
 			Paths: []string{"/" + service.Name},
 			Hosts: []string{EdgeXService},
 		}
-		routePath := fmt.Sprintf("%s%s/%s", ServicesPath, service.Name, RoutesPath)
-		initKongRoutes(baseURL, client, routeParams, routePath, service.Name)
+		count(initKongRoutes(kc, routeParams, service.Name))
 	}
 
-	initKongAdminInterface(config, baseURL, client)
-	err := loadKongCerts(config, baseURL, secretBaseURL, client)
+	count(initKongAdminInterface(config, kc))
+	err := loadKongCerts(config, kc, secretBaseURL, client)
 	if err != nil {
 		lc.Error(err.Error())
+		failed++
+	}
+	if failed > 0 {
+		s := fmt.Sprintf("Failed to set up %d parts of the reverse proxy, see the log for details.", failed)
+		lc.Error(s)
+		return errors.New(s)
 	}
 	lc.Info("Finishing initialization for reverse proxy.")
+	return nil
 }
 
-func initKongService(url string, c *http.Client, service *KongService) {
-	req, err := sling.New().Base(url).Post(ServicesPath).BodyForm(service).Request()
-	resp, err := c.Do(req)
+func kongServiceFromConfig(name string, host string, port string, protocol string) (*kong.Service, error) {
+	p, err := strconv.Atoi(port)
 	if err != nil {
-		s := fmt.Sprintf("Failed to set up proxy service for %s.", service.Name)
-		lc.Error(s)
-	} else {
-		if resp.StatusCode == 201 || resp.StatusCode == 409 {
-			lc.Info(fmt.Sprintf("Successful to set up proxy service for %s.", service.Name))
-		} else {
-			lc.Error(fmt.Sprintf("Failed to set up proxy service for %s.", service.Name))
-		}
+		s := fmt.Sprintf("Invalid port %s for service %s.", port, name)
+		return nil, errors.New(s)
 	}
+	return &kong.Service{
+		Name:     name,
+		Host:     host,
+		Port:     p,
+		Protocol: protocol,
+	}, nil
 }
 
-func initJWTAuthForService(url string, c *http.Client, path string, name string) {
-	jwtParams := &KongPlugin{
-		Name: "jwt",
-	}
-
-	req, err := sling.New().Base(url).Post(path).BodyForm(jwtParams).Request()
-	resp, err := c.Do(req)
-	if err != nil {
-		s := fmt.Sprintf("Failed to set up jwt authentication for service %s with error %s.", name, err.Error())
+func initKongService(kc *kong.Client, service *kong.Service) error {
+	_, err := kc.CreateService(service)
+	if err != nil && !kong.IsConflict(err) {
+		s := fmt.Sprintf("Failed to set up proxy service for %s with error %s.", service.Name, err.Error())
 		lc.Error(s)
-	} else {
-		if resp.StatusCode == 200 || resp.StatusCode == 201 || resp.StatusCode == 409 {
-			lc.Info(fmt.Sprintf("Successful to set up jwt authentication for service %s.", name))
-		} else {
-			s := fmt.Sprintf("Failed to set up jwt authentication for service %s with errorcode %d.", name, resp.StatusCode)
-			lc.Error(s)
-		}
+		return errors.New(s)
 	}
+	lc.Info(fmt.Sprintf("Successful to set up proxy service for %s.", service.Name))
+	return nil
 }
 
-func initKongRoutes(url string, c *http.Client, r *KongRoute, path string, name string) {
-	req, err := sling.New().Base(url).Post(path).BodyForm(r).Request()
-	resp, err := c.Do(req)
-	if err != nil {
+func initKongRoutes(kc *kong.Client, r *kong.Route, name string) error {
+	_, err := kc.CreateRoute(name, r)
+	if err != nil && !kong.IsConflict(err) {
 		s := fmt.Sprintf("Failed to set up routes for %s with error %s.", name, err.Error())
 		lc.Error(s)
-	} else {
-		if resp.StatusCode == 200 || resp.StatusCode == 201 || resp.StatusCode == 409 {
-			lc.Info(fmt.Sprintf("Successful to set up route for %s.", name))
-		} else {
-			s := fmt.Sprintf("Failed to set up route for %s with errorcode %d.", name, resp.StatusCode)
-			lc.Error(s)
-		}
+		return errors.New(s)
 	}
+	lc.Info(fmt.Sprintf("Successful to set up route for %s.", name))
+	return nil
 }
 
-//redirect request for 8001 to an admin service of 8000, and add authentication
-func initKongAdminInterface(config *tomlConfig, url string, c *http.Client) {
-	adminServiceParams := &KongService{
-		Name:     "admin",
-		Host:     config.KongURL.Server,
-		Port:     config.KongURL.AdminPort,
-		Protocol: "http",
-	}
-	req, err := sling.New().Base(url).Post(ServicesPath).BodyForm(adminServiceParams).Request()
-	resp, err := c.Do(req)
+// redirect request for 8001 to an admin service of 8000, and add authentication
+func initKongAdminInterface(config *tomlConfig, kc *kong.Client) error {
+	adminServiceParams, err := kongServiceFromConfig(AdminService, config.KongURL.Server, config.KongURL.AdminPort, "http")
 	if err != nil {
+		lc.Error(err.Error())
+		return err
+	}
+	_, err = kc.CreateService(adminServiceParams)
+	if err != nil && !kong.IsConflict(err) {
 		s := fmt.Sprintf("Failed to set up service for admin loopback with error %s.", err.Error())
 		lc.Error(s)
-	} else {
-		if resp.StatusCode == 200 || resp.StatusCode == 201 || resp.StatusCode == 409 {
-			lc.Info("Successful to set up admin loopback.")
-		} else {
-			lc.Error("Failed to set up admin loopback.")
-		}
+		return errors.New(s)
 	}
+	lc.Info("Successful to set up admin loopback.")
 
-	adminRouteParams := &KongRoute{Paths: []string{"/admin"}}
-	adminRoutePath := fmt.Sprintf("%sadmin/routes", ServicesPath)
-	req, err = sling.New().Base(url).Post(adminRoutePath).BodyForm(adminRouteParams).Request()
-	resp, err = c.Do(req)
-	if err != nil {
-		lc.Error("Failed to set up admin service route.")
-	} else {
-		if resp.StatusCode == 200 || resp.StatusCode == 201 || resp.StatusCode == 409 {
-			lc.Info("Successful to set up admin service routes.")
-		} else {
-			lc.Error("Failed to set up admin service routes.")
+	adminRouteParams := &kong.Route{Paths: []string{"/" + AdminService}}
+	_, err = kc.CreateRoute(AdminService, adminRouteParams)
+	if err != nil && !kong.IsConflict(err) {
+		s := fmt.Sprintf("Failed to set up admin service route with error %s.", err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	lc.Info("Successful to set up admin service routes.")
+
+	if err := initAuthForService(config, kc, AdminService, authentication(config, AdminService)); err != nil {
+		return err
+	}
+	if adminDirectory(config, AdminService) {
+		if err := initAuthForService(config, kc, AdminService, LDAPAuthPlugin); err != nil {
+			return err
 		}
 	}
+	return initACLForService(config, kc, AdminService)
+}
 
-	jwtAdminServicePath := "services/admin/plugins"
-	initJWTAuthForService(url, c, jwtAdminServicePath, "admin")
+// the jwt plugin only checks exp and nbf when they are listed in claims_to_verify
+func jwtPlugin(config *tomlConfig) *kong.Plugin {
+	p := &kong.Plugin{Name: JWTPlugin}
+	if len(config.JWT.ClaimsToVerify) > 0 {
+		p.Config = map[string]interface{}{"claims_to_verify": config.JWT.ClaimsToVerify}
+	}
+	return p
 }
@@ -0,0 +1,303 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+// Tokens of a trusted identity provider are verified by a consumer named after the issuer with
+// one RS256 jwt credential whose key is the iss of the tokens. Kong 0.13 looks credentials up by
+// key alone, so only one signing key of the JWKS is registered at a time, the one with the
+// configured kid or else the first rsa signing key. Every refresh registers the current key.
+
+const defaultJWKSRefreshInterval = time.Hour
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// readJWKS loads the key set of the issuer from its jwksurl or jwksfile
+func readJWKS(iss issuer, c *http.Client) (*jsonWebKeySet, error) {
+	var raw []byte
+	var err error
+	switch {
+	case iss.JWKSFile != "":
+		raw, err = ioutil.ReadFile(iss.JWKSFile)
+	case iss.JWKSURL != "":
+		raw, err = getJWKS(iss.JWKSURL, c)
+	default:
+		err = errors.New("neither jwksurl nor jwksfile is set")
+	}
+	if err != nil {
+		return nil, err
+	}
+	set := &jsonWebKeySet{}
+	if err := json.Unmarshal(raw, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// newJWKSClient returns a client that always verifies the certificate of the issuer, against the
+// pem bundle in [jwks] cafile when one is given and the system roots otherwise. It does not
+// follow --insureskipverify, a forged key set would let anybody sign tokens for every route.
+func newJWKSClient(config *tomlConfig) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.JWKS.CAFile != "" {
+		raw, err := ioutil.ReadFile(config.JWKS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(raw) {
+			s := fmt.Sprintf("No PEM encoded certificate found in %s.", config.JWKS.CAFile)
+			return nil, errors.New(s)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+	}, nil
+}
+
+func getJWKS(url string, c *http.Client) ([]byte, error) {
+	if !strings.HasPrefix(url, "https://") {
+		s := fmt.Sprintf("The jwksurl %s does not use https.", url)
+		return nil, errors.New(s)
+	}
+	resp, err := c.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s := fmt.Sprintf("Fetching %s returned errorcode %d.", url, resp.StatusCode)
+		return nil, errors.New(s)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// signingKeyPEM returns the PEM encoded public key of the key with the given kid, or of the first
+// rsa signing key when kid is empty
+func (set *jsonWebKeySet) signingKeyPEM(kid string) (string, string, error) {
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		if kid != "" && k.Kid != kid {
+			continue
+		}
+		public, err := k.rsaPublicKey()
+		if err != nil {
+			return "", "", err
+		}
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return "", "", err
+		}
+		return k.Kid, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+	}
+	if kid != "" {
+		s := fmt.Sprintf("The key set has no RS256 signing key with kid %s.", kid)
+		return "", "", errors.New(s)
+	}
+	return "", "", errors.New("The key set has no RS256 signing key.")
+}
+
+func (k jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.N, "="))
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.E, "="))
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 {
+		s := fmt.Sprintf("Invalid rsa key %s in the key set.", k.Kid)
+		return nil, errors.New(s)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// refreshIssuers brings the consumer of every configured issuer in line with its current
+// signing key, and stops at nothing but logs the issuers that fail
+func refreshIssuers(config *tomlConfig, kc *kong.Client) error {
+	if len(config.JWKS.Issuers) == 0 {
+		return nil
+	}
+	c, err := newJWKSClient(config)
+	if err != nil {
+		s := fmt.Sprintf("Failed to set up the client for the key sets with error %s.", err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+
+	names := []string{}
+	for name := range config.JWKS.Issuers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	failed := []string{}
+	for _, name := range names {
+		if err := refreshIssuer(config, kc, c, name, config.JWKS.Issuers[name]); err != nil {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		s := fmt.Sprintf("Failed to refresh the keys of issuers %s.", strings.Join(failed, ", "))
+		return errors.New(s)
+	}
+	return nil
+}
+
+func refreshIssuer(config *tomlConfig, kc *kong.Client, c *http.Client, name string, iss issuer) error {
+	if iss.Iss == "" {
+		s := fmt.Sprintf("Issuer %s has no iss.", name)
+		lc.Error(s)
+		return errors.New(s)
+	}
+	set, err := readJWKS(iss, c)
+	if err != nil {
+		s := fmt.Sprintf("Failed to read the key set of issuer %s with error %s.", name, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	kid, publicPEM, err := set.signingKeyPEM(iss.Kid)
+	if err != nil {
+		s := fmt.Sprintf("Failed to find the signing key of issuer %s with error %s.", name, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+
+	// only what is missing is created, so a refresh without changes plans no requests
+	if _, err := kc.GetConsumer(name); kong.IsNotFound(err) {
+		if err := createConsumer(config, name, "", kc, EdgeXService); err != nil {
+			lc.Error(err.Error())
+			return err
+		}
+	}
+	acls, err := kc.ListACLs(name)
+	if err != nil && !kong.IsNotFound(err) {
+		s := fmt.Sprintf("Failed to read groups of issuer %s with error %s.", name, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	groups := []string{}
+	for _, a := range acls {
+		groups = append(groups, a.Group)
+	}
+	missing := []string{}
+	for _, r := range iss.Roles {
+		if !containsString(groups, r) {
+			missing = append(missing, r)
+		}
+	}
+	if err := addConsumerGroups(config, kc, name, missing, nil); err != nil {
+		return err
+	}
+
+	creds, err := kc.ListJWTCredentials(name)
+	if err != nil && !kong.IsNotFound(err) {
+		s := fmt.Sprintf("Failed to read jwt credentials of issuer %s with error %s.", name, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	var current *kong.JWTCredential
+	for i := range creds {
+		if creds[i].Key == iss.Iss {
+			current = &creds[i]
+			continue
+		}
+		// left over from an earlier iss of the issuer
+		if err := kc.DeleteJWTCredential(name, creds[i].ID); err != nil && !kong.IsNotFound(err) {
+			lc.Error(fmt.Sprintf("Failed to delete jwt credential %s of issuer %s with error %s.", creds[i].ID, name, err.Error()))
+		}
+	}
+
+	cred := &kong.JWTCredential{Key: iss.Iss, Algorithm: "RS256", RSAPublicKey: publicPEM}
+	switch {
+	case current == nil:
+		_, err = kc.CreateJWTCredential(name, cred)
+	case current.Algorithm == "RS256" && strings.TrimSpace(current.RSAPublicKey) == strings.TrimSpace(publicPEM):
+		lc.Info(fmt.Sprintf("Issuer %s is up to date with key %s.", name, kid))
+		return nil
+	default:
+		_, err = kc.UpdateJWTCredential(name, current.ID, cred)
+	}
+	if err != nil {
+		s := fmt.Sprintf("Failed to register key %s of issuer %s with error %s.", kid, name, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	lc.Info(fmt.Sprintf("Successful to register key %s of issuer %s.", kid, name))
+	return nil
+}
+
+func jwksRefreshInterval(config *tomlConfig) (time.Duration, error) {
+	if config.JWKS.RefreshInterval == "" {
+		return defaultJWKSRefreshInterval, nil
+	}
+	interval, err := time.ParseDuration(config.JWKS.RefreshInterval)
+	if err != nil || interval <= 0 {
+		s := fmt.Sprintf("Invalid jwks refresh interval %s.", config.JWKS.RefreshInterval)
+		return 0, errors.New(s)
+	}
+	return interval, nil
+}
+
+// refreshIssuersPeriodically picks up rotated issuer keys until stop is closed
+func refreshIssuersPeriodically(config *tomlConfig, kc *kong.Client, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			refreshIssuers(config, kc)
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,269 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+func rsaJWK(t *testing.T, kid string) (jsonWebKey, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jsonWebKey{
+		Kty: "RSA", Use: "sig", Alg: "RS256", Kid: kid,
+		N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}, key
+}
+
+// verifies reports whether a token signed with key verifies with the PEM encoded public key
+func verifies(t *testing.T, key *rsa.PrivateKey, publicPEM string) bool {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"iss": "https://idp.example.com/"}).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return public, nil })
+	return err == nil
+}
+
+func TestSigningKeyPEM(t *testing.T) {
+	first, firstKey := rsaJWK(t, "first")
+	second, secondKey := rsaJWK(t, "second")
+	ec := jsonWebKey{Kty: "EC", Use: "sig", Alg: "ES256", Kid: "ec"}
+	encryption := first
+	encryption.Use, encryption.Kid = "enc", "enc"
+	rs512 := first
+	rs512.Alg, rs512.Kid = "RS512", "rs512"
+	invalid := first
+	invalid.E, invalid.Kid = "AQ", "invalid"
+
+	tests := []struct {
+		name string
+		keys []jsonWebKey
+		kid  string
+		want *rsa.PrivateKey
+	}{
+		{"first rsa signing key", []jsonWebKey{ec, encryption, rs512, second, first}, "", secondKey},
+		{"by kid", []jsonWebKey{second, first}, "first", firstKey},
+		{"without use and alg", []jsonWebKey{{Kty: "RSA", Kid: "bare", N: first.N, E: first.E}}, "", firstKey},
+		{"padded base64", []jsonWebKey{{Kty: "RSA", Kid: "padded", N: first.N + "==", E: first.E}}, "", firstKey},
+		{"unsupported kty only", []jsonWebKey{ec, {Kty: "oct", Kid: "hmac"}}, "", nil},
+		{"unknown kid", []jsonWebKey{first, second}, "third", nil},
+		{"kid of an ec key", []jsonWebKey{ec, first}, "ec", nil},
+		{"invalid exponent", []jsonWebKey{invalid}, "", nil},
+		{"invalid modulus", []jsonWebKey{{Kty: "RSA", Kid: "broken", N: "!!", E: first.E}}, "", nil},
+		{"empty set", nil, "", nil},
+	}
+	for _, tt := range tests {
+		set := &jsonWebKeySet{Keys: tt.keys}
+		kid, publicPEM, err := set.signingKeyPEM(tt.kid)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: key %s was accepted", tt.name, kid)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err.Error())
+			continue
+		}
+		if block, _ := pem.Decode([]byte(publicPEM)); block == nil || block.Type != "PUBLIC KEY" {
+			t.Errorf("%s: %q is not a PEM encoded public key", tt.name, publicPEM)
+			continue
+		}
+		if !verifies(t, tt.want, publicPEM) {
+			t.Errorf("%s: key %s is not the expected key", tt.name, kid)
+		}
+	}
+}
+
+// issuerKong keeps consumers with their acls and jwt credentials in memory and records every
+// request that changes them
+type issuerKong struct {
+	*httptest.Server
+	mutex     sync.Mutex
+	consumers map[string]bool
+	acls      map[string][]kong.ACL
+	jwts      map[string][]kong.JWTCredential
+	writes    []string
+}
+
+func startIssuerKong(t *testing.T) *issuerKong {
+	k := &issuerKong{consumers: map[string]bool{}, acls: map[string][]kong.ACL{}, jwts: map[string][]kong.JWTCredential{}}
+	k.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k.mutex.Lock()
+		defer k.mutex.Unlock()
+		if r.Method != "GET" {
+			k.writes = append(k.writes, r.Method+" "+r.URL.Path)
+		}
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case len(parts) == 1 && r.Method == "POST":
+			c := kong.Consumer{}
+			json.NewDecoder(r.Body).Decode(&c)
+			k.consumers[c.Username] = true
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(c)
+		case !k.consumers[parts[1]]:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not found"}`))
+		case len(parts) == 2 && r.Method == "GET":
+			json.NewEncoder(w).Encode(kong.Consumer{ID: parts[1], Username: parts[1]})
+		case len(parts) == 3 && parts[2] == "acls" && r.Method == "GET":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": k.acls[parts[1]]})
+		case len(parts) == 3 && parts[2] == "acls" && r.Method == "POST":
+			a := kong.ACL{}
+			json.NewDecoder(r.Body).Decode(&a)
+			k.acls[parts[1]] = append(k.acls[parts[1]], a)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(a)
+		case len(parts) == 3 && parts[2] == "jwt" && r.Method == "GET":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": k.jwts[parts[1]]})
+		case len(parts) == 3 && parts[2] == "jwt" && r.Method == "POST":
+			c := kong.JWTCredential{}
+			json.NewDecoder(r.Body).Decode(&c)
+			c.ID = fmt.Sprintf("jwt-%d", len(k.writes))
+			k.jwts[parts[1]] = append(k.jwts[parts[1]], c)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(c)
+		case len(parts) == 4 && parts[2] == "jwt" && r.Method == "PATCH":
+			update := kong.JWTCredential{}
+			json.NewDecoder(r.Body).Decode(&update)
+			for i, c := range k.jwts[parts[1]] {
+				if c.ID == parts[3] {
+					k.jwts[parts[1]][i].RSAPublicKey = update.RSAPublicKey
+					json.NewEncoder(w).Encode(k.jwts[parts[1]][i])
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return k
+}
+
+func TestRefreshIssuers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	current, currentKey := rsaJWK(t, "current")
+	keys := []jsonWebKey{{Kty: "EC", Kid: "ec"}, current}
+	var keysMutex sync.Mutex
+	jwksServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keysMutex.Lock()
+		defer keysMutex.Unlock()
+		json.NewEncoder(w).Encode(jsonWebKeySet{Keys: keys})
+	}))
+	defer jwksServer.Close()
+	proxy := startIssuerKong(t)
+	defer proxy.Close()
+	kc := kong.NewClient(proxy.URL+"/", &http.Client{})
+
+	config := syncTestConfig()
+	config.Roles["operator"] = role{Services: []string{"coredata"}}
+	config.JWKS.Issuers = map[string]issuer{"corp": {Iss: "https://idp.example.com/", JWKSURL: jwksServer.URL, Roles: []string{"operator"}}}
+
+	// the certificate of the key set is verified, and it is signed by nobody the system trusts
+	if err := refreshIssuers(config, kc); err == nil || len(proxy.writes) != 0 {
+		t.Fatalf("a key set from an untrusted server was registered: %v %v", err, proxy.writes)
+	}
+
+	config.JWKS.CAFile = filepath.Join(dir, "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: jwksServer.Certificate().Raw})
+	if err := ioutil.WriteFile(config.JWKS.CAFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := refreshIssuers(config, kc); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"POST /consumers/", "POST /consumers/corp/acls/", "POST /consumers/corp/jwt/"}
+	if !reflect.DeepEqual(proxy.writes, want) {
+		t.Errorf("registration sent %v, want %v", proxy.writes, want)
+	}
+	creds := proxy.jwts["corp"]
+	if len(creds) != 1 || creds[0].Key != "https://idp.example.com/" || creds[0].Algorithm != "RS256" || !verifies(t, currentKey, creds[0].RSAPublicKey) {
+		t.Fatalf("registered credentials %+v", creds)
+	}
+	if acls := proxy.acls["corp"]; len(acls) != 1 || acls[0].Group != "operator" {
+		t.Errorf("registered groups %+v, want operator", acls)
+	}
+
+	proxy.writes = nil
+	if err := refreshIssuers(config, kc); err != nil || len(proxy.writes) != 0 {
+		t.Errorf("a refresh without changes sent %v, %v", proxy.writes, err)
+	}
+
+	rotated, rotatedKey := rsaJWK(t, "rotated")
+	keysMutex.Lock()
+	keys = []jsonWebKey{rotated, current}
+	keysMutex.Unlock()
+	if err := refreshIssuers(config, kc); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"PATCH /consumers/corp/jwt/" + creds[0].ID}; !reflect.DeepEqual(proxy.writes, want) {
+		t.Errorf("a rotated key sent %v, want %v", proxy.writes, want)
+	}
+	if !verifies(t, rotatedKey, proxy.jwts["corp"][0].RSAPublicKey) {
+		t.Error("the rotated key was not registered")
+	}
+}
+
+func TestRefreshIssuersRefusesPlainHTTP(t *testing.T) {
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the key set was fetched over http")
+	}))
+	defer jwksServer.Close()
+	proxy := startIssuerKong(t)
+	defer proxy.Close()
+
+	config := syncTestConfig()
+	config.JWKS.Issuers = map[string]issuer{"corp": {Iss: "https://idp.example.com/", JWKSURL: jwksServer.URL}}
+	if err := refreshIssuers(config, kong.NewClient(proxy.URL+"/", &http.Client{})); err == nil || len(proxy.writes) != 0 {
+		t.Errorf("a key set over http was registered: %v %v", err, proxy.writes)
+	}
+}
@@ -0,0 +1,291 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+// Services with authentication = "ldap-auth" check the credentials of every request against the
+// directory, clients send "Authorization: ldap base64(username:password)". The plugin binds as
+// <attribute>=<username>,<basedn>, so no consumers are involved and these services get no acl
+// plugin. The admin loopback with [ldap] admin = true keeps its jwt and acl plugins and checks
+// the directory as well, the plugin also reads "Proxy-Authorization: ldap ..." which leaves the
+// Authorization header to the jwt.
+
+const (
+	LDAPAuthPlugin = "ldap-auth"
+
+	defaultLDAPTimeout  = 10000
+	defaultLDAPCacheTTL = 60
+	startTLSOID         = "1.3.6.1.4.1.1466.20037"
+)
+
+// the BER tags of the few LDAP messages used for the connectivity check
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+	berSequence    = 0x30
+
+	ldapBindRequest      = 0x60
+	ldapBindResponse     = 0x61
+	ldapUnbindRequest    = 0x42
+	ldapExtendedRequest  = 0x77
+	ldapExtendedResponse = 0x78
+	ldapSimpleAuth       = 0x80
+	ldapRequestName      = 0x80
+
+	// far more than any response to a bind, guards against a broken length from the server
+	maxBERLength = 1 << 20
+)
+
+func ldapPlugin(config *tomlConfig) *kong.Plugin {
+	port, _ := strconv.Atoi(config.LDAP.Port)
+	timeout := config.LDAP.Timeout
+	if timeout == 0 {
+		timeout = defaultLDAPTimeout
+	}
+	cacheTTL := config.LDAP.CacheTTL
+	if cacheTTL == 0 {
+		cacheTTL = defaultLDAPCacheTTL
+	}
+	return &kong.Plugin{
+		Name: LDAPAuthPlugin,
+		Config: map[string]interface{}{
+			"ldap_host":        config.LDAP.Host,
+			"ldap_port":        port,
+			"start_tls":        config.LDAP.StartTLS,
+			"verify_ldap_host": config.LDAP.VerifyLDAPHost,
+			"base_dn":          config.LDAP.BaseDN,
+			"attribute":        config.LDAP.Attribute,
+			"timeout":          timeout,
+			"cache_ttl":        cacheTTL,
+			"hide_credentials": config.LDAP.HideCredentials,
+		},
+	}
+}
+
+func validLDAP(config *tomlConfig) error {
+	if config.LDAP.Host == "" || config.LDAP.BaseDN == "" || config.LDAP.Attribute == "" {
+		return errors.New("The [ldap] section needs host, basedn and attribute for the ldap-auth services.")
+	}
+	if port, err := strconv.Atoi(config.LDAP.Port); err != nil || port <= 0 {
+		s := fmt.Sprintf("Invalid [ldap] port %s.", config.LDAP.Port)
+		return errors.New(s)
+	}
+	return nil
+}
+
+// checkLDAP connects to the directory the way the ldap-auth plugin does and binds as the given
+// user, or anonymously when user is empty
+func checkLDAP(config *tomlConfig, user string, password string) error {
+	if err := validLDAP(config); err != nil {
+		return err
+	}
+	timeout := time.Duration(config.LDAP.Timeout) * time.Millisecond
+	if timeout == 0 {
+		timeout = defaultLDAPTimeout * time.Millisecond
+	}
+	addr := net.JoinHostPort(config.LDAP.Host, config.LDAP.Port)
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var c net.Conn = conn
+	id := 1
+	if config.LDAP.StartTLS {
+		request := berTLV(ldapExtendedRequest, berTLV(ldapRequestName, []byte(startTLSOID)))
+		if err := ldapCall(c, id, request, ldapExtendedResponse, "StartTLS"); err != nil {
+			return err
+		}
+		id++
+		tc := tls.Client(conn, &tls.Config{ServerName: config.LDAP.Host, InsecureSkipVerify: !config.LDAP.VerifyLDAPHost})
+		if err := tc.Handshake(); err != nil {
+			return err
+		}
+		c = tc
+	}
+
+	dn := ""
+	if user != "" {
+		dn = fmt.Sprintf("%s=%s,%s", config.LDAP.Attribute, escapeDN(user), config.LDAP.BaseDN)
+	}
+	request := berTLV(ldapBindRequest, berConcat(
+		berTLV(berInteger, []byte{3}),
+		berTLV(berOctetString, []byte(dn)),
+		berTLV(ldapSimpleAuth, []byte(password)),
+	))
+	if err := ldapCall(c, id, request, ldapBindResponse, "Bind as "+dn); err != nil {
+		return err
+	}
+	c.Write(ldapMessage(id+1, berTLV(ldapUnbindRequest, nil)))
+	return nil
+}
+
+// escapeDN escapes an attribute value for a distinguished name as RFC 4514 asks, so a name
+// with , + or = stays one value
+func escapeDN(value string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == 0:
+			b.WriteString(`\00`)
+		case strings.IndexByte(`,+"\<>;=`, c) >= 0,
+			(c == '#' || c == ' ') && i == 0,
+			c == ' ' && i == len(value)-1:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// ldapCall sends the request and fails unless the response carries result code success
+func ldapCall(c net.Conn, id int, request []byte, responseTag byte, action string) error {
+	if _, err := c.Write(ldapMessage(id, request)); err != nil {
+		return err
+	}
+	tag, body, err := readBER(bufio.NewReader(c))
+	if err != nil {
+		return err
+	}
+	if tag != berSequence {
+		return errors.New("Unexpected response from the ldap server.")
+	}
+	r := bytes.NewReader(body)
+	if tag, _, err = readBER(r); err != nil || tag != berInteger {
+		return errors.New("Unexpected response from the ldap server.")
+	}
+	tag, op, err := readBER(r)
+	if err != nil || tag != responseTag {
+		return errors.New("Unexpected response from the ldap server.")
+	}
+
+	r = bytes.NewReader(op)
+	tag, code, err := readBER(r)
+	if err != nil || tag != berEnumerated || len(code) != 1 {
+		return errors.New("Unexpected response from the ldap server.")
+	}
+	if code[0] != 0 {
+		readBER(r)
+		_, message, _ := readBER(r)
+		s := fmt.Sprintf("%s failed with result code %d %s.", action, code[0], string(message))
+		return errors.New(s)
+	}
+	return nil
+}
+
+func ldapMessage(id int, op []byte) []byte {
+	return berTLV(berSequence, berConcat(berTLV(berInteger, berInt(id)), op))
+}
+
+func berInt(i int) []byte {
+	b := []byte{byte(i)}
+	for i > 0x7f {
+		i >>= 8
+		b = append([]byte{byte(i)}, b...)
+	}
+	return b
+}
+
+func berTLV(tag byte, value []byte) []byte {
+	l := len(value)
+	var length []byte
+	if l < 0x80 {
+		length = []byte{byte(l)}
+	} else {
+		for ; l > 0; l >>= 8 {
+			length = append([]byte{byte(l)}, length...)
+		}
+		length = append([]byte{0x80 | byte(len(length))}, length...)
+	}
+	return berConcat([]byte{tag}, length, value)
+}
+
+func berConcat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// readBER reads one element in definite length form
+func readBER(r io.ByteReader) (byte, []byte, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		n := int(first & 0x7f)
+		if n == 0 || n > 4 {
+			return 0, nil, errors.New("Unsupported ber length.")
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return 0, nil, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxBERLength {
+		return 0, nil, errors.New("Unsupported ber length.")
+	}
+	value := make([]byte, length)
+	for i := range value {
+		if value[i], err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+	}
+	return tag, value, nil
+}
+
+// readBindPassword reads the password of the test bind, prompt asks once without echoing. A
+// password can't be generated for a bind, so the default source of --password is refused
+// rather than waiting for input that never comes in a container.
+func readBindPassword(source string, user string) (string, error) {
+	switch source {
+	case "stdin":
+		return readLine(bufio.NewReader(os.Stdin))
+	case "prompt":
+		return promptLine(bufio.NewReader(os.Stdin), fmt.Sprintf("LDAP password for %s: ", user))
+	}
+	s := fmt.Sprintf("Please give the password of ldap user %s with --password=prompt or --password=stdin.", user)
+	return "", errors.New(s)
+}
@@ -0,0 +1,240 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBERInt(t *testing.T) {
+	tests := []struct {
+		value int
+		want  []byte
+	}{
+		{0, []byte{0x00}},
+		{1, []byte{0x01}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x00, 0x80}},
+		{256, []byte{0x01, 0x00}},
+		{0x8000, []byte{0x00, 0x80, 0x00}},
+	}
+	for _, tt := range tests {
+		if got := berInt(tt.value); !bytes.Equal(got, tt.want) {
+			t.Errorf("berInt(%d) = % x, want % x", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBERTLV(t *testing.T) {
+	tests := []struct {
+		length int
+		header []byte
+	}{
+		{0, []byte{0x04, 0x00}},
+		{127, []byte{0x04, 0x7f}},
+		{128, []byte{0x04, 0x81, 0x80}},
+		{255, []byte{0x04, 0x81, 0xff}},
+		{256, []byte{0x04, 0x82, 0x01, 0x00}},
+		{70000, []byte{0x04, 0x83, 0x01, 0x11, 0x70}},
+	}
+	for _, tt := range tests {
+		value := bytes.Repeat([]byte{'a'}, tt.length)
+		encoded := berTLV(berOctetString, value)
+		if !bytes.HasPrefix(encoded, tt.header) || len(encoded) != len(tt.header)+tt.length {
+			t.Errorf("length %d encoded with header % x", tt.length, encoded[:len(tt.header)])
+			continue
+		}
+		tag, decoded, err := readBER(bytes.NewReader(encoded))
+		if err != nil || tag != berOctetString || !bytes.Equal(decoded, value) {
+			t.Errorf("length %d decoded to tag %x, %d bytes, error %v", tt.length, tag, len(decoded), err)
+		}
+	}
+}
+
+func TestReadBERErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded []byte
+	}{
+		{"empty", nil},
+		{"no length", []byte{0x04}},
+		{"indefinite length", []byte{0x30, 0x80, 0x00, 0x00}},
+		{"length of five bytes", []byte{0x04, 0x85, 0x00, 0x00, 0x00, 0x00, 0x01, 'a'}},
+		{"truncated length", []byte{0x04, 0x82, 0x01}},
+		{"truncated value", []byte{0x04, 0x05, 'a', 'b'}},
+		{"too long", []byte{0x04, 0x84, 0x7f, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		if _, _, err := readBER(bytes.NewReader(tt.encoded)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestLDAPBindMessage(t *testing.T) {
+	// the anonymous simple bind of RFC 4511 as sent by ldapsearch -x
+	want := []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x60, 0x07, 0x02, 0x01, 0x03, 0x04, 0x00, 0x80, 0x00}
+	request := berTLV(ldapBindRequest, berConcat(
+		berTLV(berInteger, []byte{3}),
+		berTLV(berOctetString, nil),
+		berTLV(ldapSimpleAuth, nil),
+	))
+	if got := ldapMessage(1, request); !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+}
+
+// startLDAPServer answers StartTLS and simple binds the way OpenLDAP does, only the anonymous
+// bind and uid=alice with password secret succeed
+func startLDAPServer(t *testing.T, tlsConfig *tls.Config) (net.Listener, string, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveLDAP(c, tlsConfig)
+		}
+	}()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	return l, host, port
+}
+
+func serveLDAP(c net.Conn, tlsConfig *tls.Config) {
+	defer c.Close()
+	conn := c
+	for {
+		tag, body, err := readBER(bufio.NewReader(conn))
+		if err != nil || tag != berSequence {
+			return
+		}
+		r := bytes.NewReader(body)
+		_, id, _ := readBER(r)
+		op, request, _ := readBER(r)
+		msgID := int(id[len(id)-1])
+		switch op {
+		case ldapExtendedRequest:
+			conn.Write(ldapMessage(msgID, berTLV(ldapExtendedResponse, ldapResult(0, ""))))
+			tc := tls.Server(c, tlsConfig)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn = tc
+		case ldapBindRequest:
+			rr := bytes.NewReader(request)
+			readBER(rr)
+			_, dn, _ := readBER(rr)
+			_, password, _ := readBER(rr)
+			code := byte(49)
+			if len(dn) == 0 || ((string(dn) == "uid=alice,ou=people,dc=edgexfoundry,dc=org" || string(dn) == `uid=doe\, john,ou=people,dc=edgexfoundry,dc=org`) && string(password) == "secret") {
+				code = 0
+			}
+			conn.Write(ldapMessage(msgID, berTLV(ldapBindResponse, ldapResult(code, "invalid credentials"))))
+		default:
+			return
+		}
+	}
+}
+
+func ldapResult(code byte, message string) []byte {
+	return berConcat(berTLV(berEnumerated, []byte{code}), berTLV(berOctetString, nil), berTLV(berOctetString, []byte(message)))
+}
+
+func TestCheckLDAP(t *testing.T) {
+	// borrow the self-signed certificate of a test server for StartTLS
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+	l, host, port := startLDAPServer(t, ts.TLS)
+	defer l.Close()
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		startTLS bool
+		verify   bool
+		err      string
+	}{
+		{"anonymous", "", "", false, false, ""},
+		{"user", "alice", "secret", false, false, ""},
+		{"wrong password", "alice", "guess", false, false, "result code 49"},
+		{"unknown user", "bob", "secret", false, false, "result code 49"},
+		{"escaped user", "doe, john", "secret", false, false, ""},
+		{"starttls", "alice", "secret", true, false, ""},
+		{"starttls wrong password", "alice", "guess", true, false, "result code 49"},
+		{"starttls unverified certificate", "alice", "secret", true, true, "certificate"},
+	}
+	for _, tt := range tests {
+		config := &tomlConfig{LDAP: ldapconfig{
+			Host:           host,
+			Port:           port,
+			BaseDN:         "ou=people,dc=edgexfoundry,dc=org",
+			Attribute:      "uid",
+			StartTLS:       tt.startTLS,
+			VerifyLDAPHost: tt.verify,
+			Timeout:        2000,
+		}}
+		err := checkLDAP(config, tt.user, tt.password)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %s", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestEscapeDN(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"alice", "alice"},
+		{"doe, john", `doe\, john`},
+		{"a+b=c", `a\+b\=c`},
+		{`say "hi"; <x> \`, `say \"hi\"\; \<x\> \\`},
+		{"#tag", `\#tag`},
+		{"a#b", "a#b"},
+		{" padded ", `\ padded\ `},
+		{"a b", "a b"},
+		{"nul\x00", `nul\00`},
+		{"jürgen", "jürgen"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := escapeDN(tt.value); got != tt.want {
+			t.Errorf("escapeDN(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestReadBindPasswordDefaultSource(t *testing.T) {
+	if _, err := readBindPassword("generate", "alice"); err == nil {
+		t.Error("the default password source should be refused for a bind")
+	}
+}
@@ -24,8 +24,8 @@ import (
 	"os"
 	"time"
 
-	//"github.com/edgexfoundry/edgex-go/core/clients/metadata"
 	"github.com/edgexfoundry/edgex-go/support/logging-client"
+	"github.com/edgexfoundry/edgexsecurity/kong"
 )
 
 var lc = CreateLogging()
@@ -43,8 +43,56 @@ func main() {
 	insecureSkipVerify := flag.Bool("insureskipverify", true, "skip server side SSL verification, mainly for self-signed cert.")
 	initNeeded := flag.Bool("init", false, "run init procedure for security service.")
 	resetNeeded := flag.Bool("reset", false, "reset reverse proxy by removing all services/routes/consumers")
+	syncNeeded := flag.Bool("sync", false, "converge reverse proxy services/routes/plugins/certificates to the configuration")
+	planNeeded := flag.Bool("plan", false, "print the requests that would change the reverse proxy without sending them")
+	format := flag.String("format", "text", "output format for the plan and the user list, text, json or csv (user list only)")
+	planFile := flag.String("planout", "", "file the plan is written to instead of stdout")
 	userTobeCreated := flag.String("useradd", "", "user that needs to be added to consume the edgex services")
+	customID := flag.String("customid", "", "custom_id of the user created with --useradd linking it to e.g. an asset id")
+	userRoles := listFlags{}
+	flag.Var(&userRoles, "role", "role from [roles] the user created with --useradd gets, can be repeated or comma separated")
+	userGroups := listFlags{}
+	flag.Var(&userGroups, "group", "extra acl group the user created with --useradd is put in, can be repeated or comma separated")
+	basicAuthNeeded := flag.Bool("basicauth", false, "also create a basic-auth credential for the user created with --useradd")
+	passwordSource := flag.String("password", "generate", "where the basic-auth password comes from, generate, prompt or stdin")
+	tokenAlgorithm := flag.String("algorithm", "", "jwt algorithm of the credential created with --useradd, HS256, RS256 or ES256, defaults to [jwt] algorithm")
+	keyFile := flag.String("keyout", "", "file the private key of an RS256/ES256 credential is written to instead of stdout")
+	tokenTTL := flag.String("ttl", "", "lifetime of the jwt issued with --useradd, e.g. 24h, defaults to [jwt] ttl")
+	tokenNotBefore := flag.String("nbf", "", "jwt is not valid before this RFC3339 time or duration from now")
+	tokenAudience := flag.String("aud", "", "audience of the jwt, defaults to [jwt] audience")
+	tokenID := flag.String("jti", "", "id of the jwt, generated when empty")
+	extraClaims := claimFlags{}
+	flag.Var(extraClaims, "claim", "extra key=value claim for the jwt, can be repeated")
+	userTobeRotated := flag.String("rotate", "", "user whose jwt credential given with --credential is replaced, without --credential all jwt credentials and so every token of the user are retired after the grace period")
+	rotationGracePeriod := flag.String("grace", "", "how long the old credentials keep working after --rotate, defaults to [rotation] grace")
 	userTobeDeleted := flag.String("userdel", "", "user that needs to be deleted from the edgex services")
+	credentialList := flag.String("credlist", "", "user whose jwt credentials are listed")
+	credentialOwner := flag.String("credrevoke", "", "user whose jwt credential given with --credential is revoked")
+	credential := flag.String("credential", "", "id or key of the credential to revoke or rotate, the key of a jwt credential is the jti of the token")
+	appAdd := flag.String("appadd", "", "user who gets another oauth2 application for the oauth2 services")
+	appName := flag.String("appname", "", "name of the oauth2 application created with --appadd, defaults to the username")
+	redirectURI := flag.String("redirecturi", "", "redirect uri of the oauth2 application, defaults to [oauth2] redirecturi")
+	appList := flag.String("applist", "", "user whose oauth2 applications are listed")
+	appOwner := flag.String("apprevoke", "", "user whose oauth2 application given with --credential is revoked")
+	apiKeyAdd := flag.String("keyadd", "", "user who gets another api key for the key-auth services")
+	apiKeyList := flag.String("keylist", "", "user whose api keys are listed")
+	apiKeyOwner := flag.String("keyrevoke", "", "user whose api key given with --credential is revoked")
+	tokenGet := flag.String("tokenget", "", "user whose current jwt is read from the secret service, with --format=json all stored credentials")
+	revocationList := flag.Bool("revocations", false, "list the revoked jwt credentials")
+	userList := flag.Bool("userlist", false, "list the users with their jwt credentials and groups")
+	userFilter := flag.String("filter", "", "only list users whose name or custom_id contains this text")
+	issuersNeeded := flag.Bool("issuers", false, "register the current signing keys of the trusted issuers in [jwks], also done by --init and --sync")
+	importFile := flag.String("import", "", "csv or json file of users to create, with username, custom_id, roles, groups, auth and ttl")
+	importOut := flag.String("importout", "", "file the credentials of the imported users are written to, readable by the owner only")
+	exportFile := flag.String("export", "", "csv or json file the consumers are written to in the import format, - for stdout")
+	workers := flag.Int("workers", defaultBulkWorkers, "number of users --import creates at the same time")
+	ldapCheck := flag.Bool("ldapcheck", false, "test the connection to the [ldap] directory with a bind and exit")
+	ldapUser := flag.String("ldapuser", "", "user the --ldapcheck bind is done as, anonymous when empty, the password is read as given with --password")
+	bundleFile := flag.String("out", "", "file the credentials of --useradd, --rotate, --keyadd, --appadd or --tokenget are written to as json instead of stdout, readable by the owner only")
+	recipient := flag.String("recipient", "", "PEM encoded RSA public key the --out file is encrypted to")
+	bundleToDecrypt := flag.String("decrypt", "", "encrypted --out file to open with the private key given with --decryptkey and exit")
+	decryptKey := flag.String("decryptkey", "", "PEM encoded RSA private key of the recipient used by --decrypt")
+	serveNeeded := flag.Bool("serve", false, "keep running and serve the account management REST API")
 
 	flag.Usage = HelpCallback
 	flag.Parse()
@@ -57,52 +105,544 @@ func main() {
 
 	if *useConsul {
 		lc.Info("Retrieving config data from Consul")
-		//err := metadata.ConnectToConsul(*config)
-		//if err != nil {
-		//	lc.Error("Failed to retrieve config from Consul")
-		//}
-		//lc.Info("Retrieving config data from Consul")
+		err := loadConsulConfig(config, &http.Client{Timeout: 10 * time.Second})
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to retrieve config from Consul with error %s.", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	if *bundleToDecrypt != "" {
+		raw, err := decryptBundle(*bundleToDecrypt, *decryptKey)
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to decrypt %s with error %s.", *bundleToDecrypt, err.Error()))
+			os.Exit(1)
+		}
+		os.Stdout.Write(raw)
+		fmt.Println()
+		return
+	}
+
+	if *recipient != "" && *bundleFile == "" {
+		lc.Error("Please give the file the encrypted credentials are written to with --out.")
+		os.Exit(1)
+	}
+
+	if *ldapCheck == true {
+		password := ""
+		if *ldapUser != "" {
+			password, err = readBindPassword(*passwordSource, *ldapUser)
+			if err != nil {
+				lc.Error(err.Error())
+				os.Exit(1)
+			}
+		}
+		err = checkLDAP(config, *ldapUser, password)
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to bind to ldap server %s:%s with error %s.", config.LDAP.Host, config.LDAP.Port, err.Error()))
+			os.Exit(1)
+		}
+		lc.Info(fmt.Sprintf("Successful to bind to ldap server %s:%s.", config.LDAP.Host, config.LDAP.Port))
+		fmt.Println(fmt.Sprintf("The ldap server %s:%s accepted the bind.", config.LDAP.Host, config.LDAP.Port))
+		return
 	}
 
 	proxyBaseURL := fmt.Sprintf("http://%s:%s/", config.KongURL.Server, config.KongURL.AdminPort)
-	secretServiceBaseURL := fmt.Sprintf("https://%s:%s/", config.SecretService.Server, config.SecretService.Port)
+	secretServiceProtocol := config.SecretService.Protocol
+	if secretServiceProtocol == "" {
+		secretServiceProtocol = "https"
+	}
+	secretServiceBaseURL := fmt.Sprintf("%s://%s:%s/", secretServiceProtocol, config.SecretService.Server, config.SecretService.Port)
 
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: *insecureSkipVerify},
 	}
-	client := &http.Client{Timeout: 10 * time.Second, Transport: tr}
+	probe := &http.Client{Timeout: 10 * time.Second, Transport: tr}
+
+	b, err := newBackoff(config.Retry)
+	if err != nil {
+		lc.Error(err.Error())
+		os.Exit(1)
+	}
+	// the timeout applies to every attempt of the retry transport rather than to all of them
+	client := &http.Client{Transport: newRetryTransport(tr, b, 10*time.Second)}
+
+	var plan *planTransport
+	if *planNeeded == true {
+		plan = newPlanTransport(client.Transport)
+		client.Transport = plan
+	}
+	kc := kong.NewClient(proxyBaseURL, client)
+	kc.SetPageSize(config.KongURL.PageSize)
 
-	checkProxyStatus(proxyBaseURL, client)
-	checkSecretServiceStatus(secretServiceBaseURL+config.SecretService.HealthcheckPath, client)
+	err = waitFor("reverse proxy", b, func() error {
+		return checkProxyStatus(kong.NewClient(proxyBaseURL, probe))
+	})
+	if err != nil {
+		lc.Error(err.Error())
+		os.Exit(1)
+	}
+	err = waitFor("secret service", b, func() error {
+		return checkSecretServiceStatus(secretServiceBaseURL+config.SecretService.HealthcheckPath, probe, config.SecretService.StandbyOK)
+	})
+	if err != nil {
+		lc.Error(err.Error())
+		os.Exit(1)
+	}
 
 	if *initNeeded == true && *resetNeeded == true {
 		lc.Error("can't run initialization and reset at the same time for security service.")
 		return
 	}
 
+	if *syncNeeded == true && (*initNeeded == true || *resetNeeded == true) {
+		lc.Error("can't run sync together with initialization or reset for security service.")
+		return
+	}
+
+	if *serveNeeded == true && plan != nil {
+		lc.Error("can't run the security service REST API in plan mode.")
+		return
+	}
+
+	credStore, err := newCredentialStore(config, secretServiceBaseURL, client)
+	if err != nil {
+		lc.Error(err.Error())
+		os.Exit(1)
+	}
+	masked := credStore != nil
+
+	// the commands that change the reverse proxy anyway, and the daemon, retire the credentials
+	// of earlier rotations whose grace period has passed and remove revoked credentials that have
+	// come back. Read-only commands leave them alone.
+	maintenance := *initNeeded == true || *syncNeeded == true || *userTobeRotated != "" || *serveNeeded == true
+	if maintenance {
+		reapRotations(config, kc, plan != nil)
+		enforceRevocations(config, kc)
+	}
+
 	if *initNeeded == true {
-		initSecurityServices(config, proxyBaseURL, secretServiceBaseURL, client)
+		err := initSecurityServices(config, kc, secretServiceBaseURL, client)
+		if err != nil {
+			os.Exit(1)
+		}
 	}
 
 	if *resetNeeded == true {
-		resetProxy(proxyBaseURL, client)
+		err := resetProxy(kc)
+		if err != nil {
+			os.Exit(1)
+		}
+	}
+
+	if *syncNeeded == true {
+		err := syncProxy(config, kc, secretServiceBaseURL, client)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *initNeeded == true || *syncNeeded == true || *issuersNeeded == true {
+		err := refreshIssuers(config, kc)
+		if err != nil {
+			lc.Error(err.Error())
+			if *issuersNeeded == true {
+				os.Exit(1)
+			}
+		}
 	}
 
 	if *userTobeCreated != "" {
-		err := createConsumer(*userTobeCreated, proxyBaseURL, EdgeXService, client)
+		opts, err := newTokenOptions(config, *tokenAlgorithm, *tokenTTL, *tokenNotBefore, *tokenAudience, *tokenID, extraClaims)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		opts.Signer, err = newTransitSigner(config, secretServiceBaseURL, client, plan != nil)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		err = validRoles(config, userRoles)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		auths := requiredAuthentications(config, userRoles)
+		if *basicAuthNeeded == true && !containsString(auths, BasicAuthPlugin) {
+			auths = append(auths, BasicAuthPlugin)
+		}
+		password := ""
+		if containsString(auths, BasicAuthPlugin) {
+			password, err = readPassword(*passwordSource, *userTobeCreated)
+			if err != nil {
+				lc.Error(err.Error())
+				os.Exit(1)
+			}
+		}
+		// a consumer created here is removed again when it can't be completed, an existing one is kept
+		_, err = kc.GetConsumer(*userTobeCreated)
+		created := kong.IsNotFound(err)
+		err = createConsumer(config, *userTobeCreated, *customID, kc, EdgeXService)
 		if err != nil {
 			lc.Error(err.Error())
 			return
 		}
-		t, err := createJWTForConsumer(*userTobeCreated, proxyBaseURL, EdgeXService, client)
+		err = addConsumerGroups(config, kc, *userTobeCreated, userRoles, userGroups)
 		if err != nil {
-			lc.Error("Failed to create jwt token for edgex service due to error %s.", err.Error())
-		} else {
-			fmt.Println(fmt.Sprintf("The JWT for user %s is: %s. Please keep the jwt for accessing edgex services.", *userTobeCreated, t))
+			lc.Error(fmt.Sprintf("Failed to add consumer %s to its groups, the acl plugin would refuse it.", *userTobeCreated))
+			if created {
+				deleteConsumer(*userTobeCreated, kc)
+			}
+			os.Exit(1)
+		}
+		keyStore, err := newAPIKeyStore(config, secretServiceBaseURL, client)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		creds, err := provisionCredentials(kc, *userTobeCreated, auths, accountOptions{
+			Token:       opts,
+			Password:    password,
+			KeyStore:    keyStore,
+			RedirectURI: oauth2RedirectURI(config, *redirectURI),
+		})
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to create credentials for edgex services due to error %s.", err.Error()))
+			// what was created before the failure is removed again, with the consumer if it is new
+			if creds != nil {
+				removeCredentials(kc, *userTobeCreated, creds, keyStore)
+			}
+			if created {
+				deleteConsumer(*userTobeCreated, kc)
+			}
+			os.Exit(1)
+		} else if plan == nil {
+			if credStore != nil {
+				if err := saveOrRollback(credStore, kc, *userTobeCreated, creds, keyStore); err != nil {
+					os.Exit(1)
+				}
+			}
+			if *bundleFile != "" {
+				err = outputCredentialBundle(config, credentialsOf(*userTobeCreated, creds), *keyFile, *bundleFile, *recipient)
+			} else {
+				err = outputCredentials(*userTobeCreated, creds, *keyFile, masked)
+			}
+			if err != nil {
+				lc.Error(fmt.Sprintf("Failed to write the credentials with error %s.", err.Error()))
+				os.Exit(1)
+			}
+		}
+	}
+
+	if *importFile != "" {
+		if *importOut == "" && plan == nil {
+			lc.Error("Please give the file the credentials of the imported users are written to with --importout.")
+			os.Exit(1)
+		}
+		users, err := readBulkUsers(*importFile, *format)
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to read users from %s with error %s.", *importFile, err.Error()))
+			os.Exit(1)
+		}
+		base := accountOptions{RedirectURI: oauth2RedirectURI(config, *redirectURI)}
+		base.Token.Signer, err = newTransitSigner(config, secretServiceBaseURL, client, plan != nil)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		base.KeyStore, err = newAPIKeyStore(config, secretServiceBaseURL, client)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		var store *credentialStore
+		if plan == nil {
+			store = credStore
+		}
+		results := importUsers(config, kc, users, *workers, base, store)
+		failed := 0
+		for _, r := range results {
+			if r.Error != "" {
+				lc.Error(fmt.Sprintf("Failed to import user %s with error %s", r.Username, r.Error))
+				failed++
+			}
+		}
+		lc.Info(fmt.Sprintf("Imported %d of %d users from %s.", len(results)-failed, len(results), *importFile))
+		if plan == nil {
+			err := outputBulkResults(results, *importOut, *format)
+			if err != nil {
+				lc.Error(fmt.Sprintf("Failed to write the credentials of the imported users with error %s.", err.Error()))
+				os.Exit(1)
+			}
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	}
+
+	if *userTobeRotated != "" {
+		opts, err := newTokenOptions(config, *tokenAlgorithm, *tokenTTL, *tokenNotBefore, *tokenAudience, *tokenID, extraClaims)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		opts.Signer, err = newTransitSigner(config, secretServiceBaseURL, client, plan != nil)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		grace, err := rotationGrace(config, *rotationGracePeriod)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		issued, err := rotateJWTForConsumer(config, *userTobeRotated, *credential, kc, opts, grace, plan != nil)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		if plan == nil {
+			if credStore != nil {
+				if err := credStore.save(*userTobeRotated, jwtCredentialsOf(*userTobeRotated, issued)); err != nil {
+					// the old credentials are already on their way out, so the new one stays
+					lc.Error(fmt.Sprintf("The new jwt of consumer %s could not be stored, please run --rotate=%s again once the secret service is back.", *userTobeRotated, *userTobeRotated))
+					os.Exit(1)
+				}
+			}
+			if *bundleFile != "" {
+				err = outputCredentialBundle(config, jwtCredentialsOf(*userTobeRotated, issued), *keyFile, *bundleFile, *recipient)
+			} else {
+				err = outputJWT(*userTobeRotated, issued, *keyFile, masked)
+			}
+			if err != nil {
+				lc.Error(fmt.Sprintf("Failed to write the credentials with error %s.", err.Error()))
+				os.Exit(1)
+			}
+		}
+	}
+
+	if *credentialOwner != "" {
+		if *credential == "" {
+			lc.Error("Please give the id or key of the jwt credential to revoke with --credential.")
+			os.Exit(1)
+		}
+		err := revokeCredential(config, kc, *credentialOwner, *credential, plan != nil)
+		if err != nil {
+			os.Exit(1)
+		}
+	}
+
+	if *apiKeyAdd != "" {
+		keyStore, err := newAPIKeyStore(config, secretServiceBaseURL, client)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		cred, err := createAPIKey(kc, *apiKeyAdd, keyStore)
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to create api key for consumer %s with error %s.", *apiKeyAdd, err.Error()))
+			os.Exit(1)
+		}
+		lc.Info(fmt.Sprintf("Successful to create api key %s for consumer %s.", cred.ID, *apiKeyAdd))
+		if plan == nil {
+			if credStore != nil {
+				if err := saveOrRollback(credStore, kc, *apiKeyAdd, &consumerCredentials{KeyAuth: cred}, keyStore); err != nil {
+					os.Exit(1)
+				}
+			}
+			if *bundleFile != "" {
+				err = outputCredentialBundle(config, userCredentials{Username: *apiKeyAdd, APIKey: cred.Key}, "", *bundleFile, *recipient)
+				if err != nil {
+					lc.Error(fmt.Sprintf("Failed to write the credentials with error %s.", err.Error()))
+					os.Exit(1)
+				}
+			} else {
+				outputAPIKey(*apiKeyAdd, cred.Key, masked)
+			}
+		}
+	}
+
+	if *appAdd != "" {
+		app, err := createOAuth2App(kc, *appAdd, *appName, oauth2RedirectURI(config, *redirectURI))
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to create oauth2 application for consumer %s with error %s.", *appAdd, err.Error()))
+			os.Exit(1)
+		}
+		lc.Info(fmt.Sprintf("Successful to create oauth2 application %s for consumer %s.", app.ClientID, *appAdd))
+		if plan == nil {
+			if credStore != nil {
+				if err := saveOrRollback(credStore, kc, *appAdd, &consumerCredentials{OAuth2: app}, nil); err != nil {
+					os.Exit(1)
+				}
+			}
+			if *bundleFile != "" {
+				err = outputCredentialBundle(config, userCredentials{Username: *appAdd, OAuth2ClientID: app.ClientID, OAuth2ClientSecret: app.ClientSecret}, "", *bundleFile, *recipient)
+				if err != nil {
+					lc.Error(fmt.Sprintf("Failed to write the credentials with error %s.", err.Error()))
+					os.Exit(1)
+				}
+			} else {
+				outputOAuth2App(*appAdd, app, masked)
+			}
+		}
+	}
+
+	if *appOwner != "" {
+		if *credential == "" {
+			lc.Error("Please give the client id or id of the oauth2 application to revoke with --credential.")
+			os.Exit(1)
+		}
+		err := revokeOAuth2App(kc, *appOwner, *credential)
+		if err != nil {
+			os.Exit(1)
+		}
+	}
+
+	if *apiKeyOwner != "" {
+		if *credential == "" {
+			lc.Error("Please give the id or key of the api key to revoke with --credential.")
+			os.Exit(1)
+		}
+		keyStore, err := newAPIKeyStore(config, secretServiceBaseURL, client)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		err = revokeAPIKey(kc, *apiKeyOwner, *credential, keyStore)
+		if err != nil {
+			os.Exit(1)
 		}
 	}
 
 	if *userTobeDeleted != "" {
-		deleteConsumer(*userTobeDeleted, proxyBaseURL, client)
+		// the stored credentials are only cleared once the consumer is gone from the reverse proxy
+		err := deleteConsumer(*userTobeDeleted, kc)
+		if err != nil {
+			os.Exit(1)
+		}
+		keyStore, err := newAPIKeyStore(config, secretServiceBaseURL, client)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		if keyStore != nil {
+			err = keyStore.clear(*userTobeDeleted)
+			if err != nil {
+				lc.Error(fmt.Sprintf("Failed to remove the stored api keys of consumer %s with error %s.", *userTobeDeleted, err.Error()))
+				os.Exit(1)
+			}
+		}
+		if credStore != nil {
+			err = credStore.clear(*userTobeDeleted)
+			if err != nil {
+				lc.Error(fmt.Sprintf("Failed to remove the stored credentials of consumer %s with error %s.", *userTobeDeleted, err.Error()))
+				os.Exit(1)
+			}
+		}
+	}
+
+	if *userList == true {
+		users, err := listUsers(kc, *userFilter)
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to list users with error %s.", err.Error()))
+			os.Exit(1)
+		}
+		err = writeUsers(users, *format, os.Stdout)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *credentialList != "" {
+		creds, err := listCredentials(kc, *credentialList)
+		if err != nil {
+			os.Exit(1)
+		}
+		err = writeCredentials(creds, *format, os.Stdout)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *appList != "" {
+		apps, err := listOAuth2Apps(kc, *appList)
+		if err != nil {
+			os.Exit(1)
+		}
+		err = writeOAuth2Apps(apps, *format, os.Stdout)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *exportFile != "" {
+		users, err := exportUsers(config, kc, *workers)
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to export users with error %s.", err.Error()))
+			os.Exit(1)
+		}
+		err = outputBulkUsers(users, *exportFile, *format)
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to write the exported users with error %s.", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	if *apiKeyList != "" {
+		keys, err := listAPIKeys(kc, *apiKeyList)
+		if err != nil {
+			os.Exit(1)
+		}
+		err = writeCredentials(keys, *format, os.Stdout)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *tokenGet != "" {
+		stored, err := getStoredToken(config, credStore, *tokenGet)
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+		if *bundleFile != "" {
+			err = outputCredentialBundle(config, *stored, "", *bundleFile, *recipient)
+		} else {
+			err = writeStoredToken(stored, *format, os.Stdout)
+		}
+		if err != nil {
+			lc.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *revocationList == true {
+		err := writeRevocations(config, *format, os.Stdout)
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to list revocations with error %s.", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	if plan != nil {
+		err := outputPlan(plan, *format, *planFile)
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to write the plan with error %s.", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	if *serveNeeded == true {
+		err := runServer(config, kc, secretServiceBaseURL, client, probe)
+		if err != nil {
+			lc.Error(fmt.Sprintf("Security service REST API stopped with error %s.", err.Error()))
+			os.Exit(1)
+		}
 	}
 }
@@ -0,0 +1,134 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+// Kong's defaults, a token lives two hours and only works on the service it was issued for
+const (
+	defaultOAuth2TokenExpiration = 7200
+	defaultOAuth2RedirectURI     = "http://localhost/"
+)
+
+// oauth2Plugin enables the client credentials grant, applications exchange their client id and
+// secret for a bearer token at https://<proxy>:8443/<service>/oauth2/token
+func oauth2Plugin(config *tomlConfig) *kong.Plugin {
+	expiration := config.OAuth2.TokenExpiration
+	if expiration == 0 {
+		expiration = defaultOAuth2TokenExpiration
+	}
+	return &kong.Plugin{
+		Name: OAuth2Plugin,
+		Config: map[string]interface{}{
+			"enable_client_credentials": true,
+			"token_expiration":          expiration,
+			"global_credentials":        config.OAuth2.GlobalCredentials,
+		},
+	}
+}
+
+// the client credentials grant never redirects, but Kong requires a redirect uri per application
+func oauth2RedirectURI(config *tomlConfig, redirectURI string) string {
+	if redirectURI != "" {
+		return redirectURI
+	}
+	if config.OAuth2.RedirectURI != "" {
+		return config.OAuth2.RedirectURI
+	}
+	return defaultOAuth2RedirectURI
+}
+
+// createOAuth2App registers an application for the consumer, Kong generates the client id
+// and secret. The name defaults to the username.
+func createOAuth2App(kc *kong.Client, user string, name string, redirectURI string) (*kong.OAuth2Credential, error) {
+	if name == "" {
+		name = user
+	}
+	return kc.CreateOAuth2Credential(user, &kong.OAuth2Credential{Name: name, RedirectURI: []string{redirectURI}})
+}
+
+// listOAuth2Apps lists the applications of the consumer without their client secrets
+func listOAuth2Apps(kc *kong.Client, user string) ([]kong.OAuth2Credential, error) {
+	apps, err := kc.ListOAuth2Credentials(user)
+	if err != nil {
+		s := fmt.Sprintf("Failed to read oauth2 applications of consumer %s with error %s.", user, err.Error())
+		lc.Error(s)
+		return nil, errors.New(s)
+	}
+	for i := range apps {
+		apps[i].ClientSecret = ""
+	}
+	return apps, nil
+}
+
+// revokeOAuth2App deletes the application with the given client id or id, the tokens it
+// obtained stop working with it
+func revokeOAuth2App(kc *kong.Client, user string, clientIDOrID string) error {
+	err := kc.DeleteOAuth2Credential(user, clientIDOrID)
+	if kong.IsNotFound(err) {
+		err := credentialNotFound{kind: OAuth2Plugin, user: user, idOrKey: clientIDOrID}
+		lc.Error(err.Error())
+		return err
+	}
+	if err != nil {
+		s := fmt.Sprintf("Failed to revoke oauth2 application %s of consumer %s with error %s.", clientIDOrID, user, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	lc.Info(fmt.Sprintf("Successful to revoke oauth2 application %s of consumer %s.", clientIDOrID, user))
+	return nil
+}
+
+func writeOAuth2Apps(apps []kong.OAuth2Credential, format string, w io.Writer) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(apps)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "name", "client_id", "redirect_uri", "created_at"})
+		for _, a := range apps {
+			cw.Write([]string{a.ID, a.Name, a.ClientID, strings.Join(a.RedirectURI, " "), formatKongTime(a.CreatedAt)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "text", "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tCLIENT ID\tREDIRECT URI\tCREATED")
+		for _, a := range apps {
+			fmt.Fprintln(tw, strings.Join([]string{a.ID, a.Name, a.ClientID, strings.Join(a.RedirectURI, " "), formatKongTime(a.CreatedAt)}, "\t"))
+		}
+		return tw.Flush()
+	}
+	s := fmt.Sprintf("Unsupported oauth2 application list format %s.", format)
+	return errors.New(s)
+}
+
+func outputOAuth2App(user string, app *kong.OAuth2Credential, masked bool) {
+	fmt.Println(fmt.Sprintf("The oauth2 client for user %s is %s with secret: %s.", user, app.ClientID, secretOutput(app.ClientSecret, masked)))
+}
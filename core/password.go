@@ -0,0 +1,109 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const minPasswordLength = 8
+
+// readPassword returns the basic-auth password from the given source: generate makes up a
+// random one, stdin reads the first line of the standard input and prompt asks on the terminal
+// twice without echoing.
+func readPassword(source string, user string) (string, error) {
+	switch source {
+	case "", "generate":
+		return randomSecret()
+	case "stdin":
+		password, err := readLine(bufio.NewReader(os.Stdin))
+		if err != nil {
+			return "", err
+		}
+		if err := checkPassword(password); err != nil {
+			return "", err
+		}
+		return password, nil
+	case "prompt":
+		in := bufio.NewReader(os.Stdin)
+		password, err := promptLine(in, fmt.Sprintf("Password for %s: ", user))
+		if err != nil {
+			return "", err
+		}
+		if err := checkPassword(password); err != nil {
+			return "", err
+		}
+		confirm, err := promptLine(in, "Repeat password: ")
+		if err != nil {
+			return "", err
+		}
+		if confirm != password {
+			return "", errors.New("Passwords do not match.")
+		}
+		return password, nil
+	}
+	s := fmt.Sprintf("Unsupported password source %s, expected generate, prompt or stdin.", source)
+	return "", errors.New(s)
+}
+
+func checkPassword(password string) error {
+	if len(password) < minPasswordLength {
+		s := fmt.Sprintf("The password needs at least %d characters.", minPasswordLength)
+		return errors.New(s)
+	}
+	return nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// echo is turned off with stty where the standard input is a terminal, which covers the linux
+// images the service runs in
+func promptLine(r *bufio.Reader, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if isTerminal(os.Stdin) {
+		if err := stty("-echo"); err == nil {
+			defer func() {
+				stty("echo")
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	return readLine(r)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
@@ -0,0 +1,339 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type plannedCall struct {
+	Action string                 `json:"action"`
+	Object string                 `json:"object"`
+	Name   string                 `json:"name"`
+	Method string                 `json:"method"`
+	Path   string                 `json:"path"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+type planOutput struct {
+	Calls   []plannedCall  `json:"calls"`
+	Summary map[string]int `json:"summary"`
+}
+
+var planObjects = map[string]string{
+	"services":     "service",
+	"routes":       "route",
+	"plugins":      "plugin",
+	"consumers":    "consumer",
+	"certificates": "certificate",
+	"jwt":          "jwt credential",
+	"keys":         "transit key",
+}
+
+var sensitiveFields = []string{"cert", "key", "secret", "password", "private_key", "client_secret"}
+
+// planTransport lets read requests through to the real server and records every mutating
+// request instead of sending it, answering with a synthetic success so the caller carries on
+// exactly as it would against a live reverse proxy. A created object comes back with the fields
+// it was sent with and a placeholder id, so that later requests of the plan address it by that.
+type planTransport struct {
+	next    http.RoundTripper
+	mutex   sync.Mutex
+	calls   []plannedCall
+	created int
+	names   map[string]string
+}
+
+func newPlanTransport(next http.RoundTripper) *planTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &planTransport{next: next, names: map[string]string{}}
+}
+
+func (p *planTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == "GET" || req.Method == "HEAD" {
+		return p.next.RoundTrip(req)
+	}
+
+	call, fields, err := p.describe(req)
+	if err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	p.calls = append(p.calls, call)
+	if req.Method == "POST" {
+		p.created++
+		fields["id"] = fmt.Sprintf("planned-%s-%d", strings.Replace(call.Object, " ", "-", -1), p.created)
+		p.names[fields["id"].(string)] = call.Name
+	}
+	p.mutex.Unlock()
+
+	status := 201
+	switch req.Method {
+	case "PATCH", "PUT":
+		status = 200
+		segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+		fields["id"] = segments[len(segments)-1]
+	case "DELETE":
+		status = 204
+	}
+	reply, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if status == 204 {
+		reply = nil
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(reply)),
+		Request:    req,
+	}, nil
+}
+
+// describe returns the call for the plan, with sensitive fields hidden, and the fields of the
+// request as they were sent
+func (p *planTransport) describe(req *http.Request) (plannedCall, map[string]interface{}, error) {
+	call := plannedCall{
+		Method: req.Method,
+		Path:   strings.TrimPrefix(req.URL.Path, "/"),
+	}
+	switch req.Method {
+	case "POST":
+		call.Action = "create"
+	case "DELETE":
+		call.Action = "delete"
+	default:
+		call.Action = "update"
+	}
+
+	fields, err := requestFields(req)
+	if err != nil {
+		return call, nil, err
+	}
+
+	segments := strings.Split(strings.Trim(call.Path, "/"), "/")
+	last := segments[len(segments)-1]
+	if object, ok := planObjects[last]; ok {
+		// creating inside a collection, e.g. services/ or services/coredata/routes/
+		call.Object = object
+		call.Name = nameFromFields(fields)
+		if call.Name == "" && len(segments) > 1 {
+			call.Name = segments[len(segments)-2]
+		}
+	} else {
+		collection := ""
+		if len(segments) > 1 {
+			collection = segments[len(segments)-2]
+		}
+		call.Object = planObjects[collection]
+		if call.Object == "" {
+			call.Object = collection
+		}
+		call.Name = p.resolveName(req, last)
+	}
+
+	if len(fields) > 0 {
+		call.Fields = redact(fields).(map[string]interface{})
+	}
+	return call, fields, nil
+}
+
+// redact returns a copy of a request body with the sensitive fields hidden at any depth, e.g.
+// the config.secret of a plugin
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, item := range v {
+			if isSensitive(k) {
+				out[k] = "(sensitive)"
+			} else {
+				out[k] = redact(item)
+			}
+		}
+		return out
+	case []interface{}:
+		out := []interface{}{}
+		for _, item := range v {
+			out = append(out, redact(item))
+		}
+		return out
+	}
+	return value
+}
+
+// form bodies name nested fields config.secret
+func isSensitive(field string) bool {
+	return containsString(sensitiveFields, field[strings.LastIndex(field, ".")+1:])
+}
+
+// objects addressed by id are looked up so the plan can show a readable name
+func (p *planTransport) resolveName(req *http.Request, id string) string {
+	p.mutex.Lock()
+	name, planned := p.names[id]
+	p.mutex.Unlock()
+	if planned {
+		return name
+	}
+	lookup, err := http.NewRequest("GET", req.URL.String(), nil)
+	if err != nil {
+		return id
+	}
+	resp, err := p.next.RoundTrip(lookup)
+	if err != nil {
+		return id
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return id
+	}
+	fields := map[string]interface{}{}
+	if err := json.NewDecoder(resp.Body).Decode(&fields); err != nil {
+		return id
+	}
+	if name := nameFromFields(fields); name != "" {
+		return name
+	}
+	return id
+}
+
+func requestFields(req *http.Request) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if req.Body == nil {
+		return fields, nil
+	}
+	raw, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	if len(raw) == 0 {
+		return fields, nil
+	}
+
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		err := json.Unmarshal(raw, &fields)
+		return fields, err
+	}
+	values, err := url.ParseQuery(string(raw))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range values {
+		if len(v) == 1 && !strings.HasSuffix(k, "[]") {
+			fields[k] = v[0]
+		} else {
+			fields[strings.TrimSuffix(k, "[]")] = v
+		}
+	}
+	return fields, nil
+}
+
+func nameFromFields(fields map[string]interface{}) string {
+	for _, k := range []string{"name", "username", "paths", "snis"} {
+		switch v := fields[k].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case []interface{}:
+			parts := []string{}
+			for _, item := range v {
+				parts = append(parts, fmt.Sprint(item))
+			}
+			if len(parts) > 0 {
+				return strings.Join(parts, ",")
+			}
+		case []string:
+			if len(v) > 0 {
+				return strings.Join(v, ",")
+			}
+		}
+	}
+	return ""
+}
+
+func (p *planTransport) output() planOutput {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	out := planOutput{Calls: p.calls, Summary: map[string]int{"create": 0, "update": 0, "delete": 0}}
+	if out.Calls == nil {
+		out.Calls = []plannedCall{}
+	}
+	for _, c := range p.calls {
+		out.Summary[c.Action]++
+	}
+	return out
+}
+
+func writePlan(p *planTransport, format string, w io.Writer) error {
+	out := p.output()
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to delete.\n", out.Summary["create"], out.Summary["update"], out.Summary["delete"])
+	for i, c := range out.Calls {
+		fmt.Fprintf(w, "%3d. %s %s %s (%s %s)\n", i+1, c.Action, c.Object, c.Name, c.Method, c.Path)
+		keys := []string{}
+		for k := range c.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "       %s = %v\n", k, c.Fields[k])
+		}
+	}
+	return nil
+}
+
+func outputPlan(p *planTransport, format string, path string) error {
+	if format != "text" && format != "json" {
+		s := fmt.Sprintf("Unsupported plan format %s.", format)
+		return errors.New(s)
+	}
+	if path == "" {
+		return writePlan(p, format, os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writePlan(p, format, f)
+}
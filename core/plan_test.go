@@ -0,0 +1,128 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+// planKong answers reads like an empty Kong and fails the test on anything that would change it
+func planKong(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("plan sent %s %s to kong", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "Not found"}`))
+	}))
+}
+
+func TestPlanRedactsNestedFields(t *testing.T) {
+	tests := []struct {
+		name string
+		in   map[string]interface{}
+		want map[string]interface{}
+	}{
+		{"top level", map[string]interface{}{"cert": "pem", "snis": "edgex.com"},
+			map[string]interface{}{"cert": "(sensitive)", "snis": "edgex.com"}},
+		{"plugin config", map[string]interface{}{"name": "jwt", "config": map[string]interface{}{"secret": "s", "claims_to_verify": []interface{}{"exp"}}},
+			map[string]interface{}{"name": "jwt", "config": map[string]interface{}{"secret": "(sensitive)", "claims_to_verify": []interface{}{"exp"}}}},
+		{"in a list", map[string]interface{}{"items": []interface{}{map[string]interface{}{"password": "p", "username": "u"}}},
+			map[string]interface{}{"items": []interface{}{map[string]interface{}{"password": "(sensitive)", "username": "u"}}}},
+		{"form key", map[string]interface{}{"config.password": "p", "config.ldap_host": "ldap"},
+			map[string]interface{}{"config.password": "(sensitive)", "config.ldap_host": "ldap"}},
+		{"oauth2", map[string]interface{}{"client_id": "id", "client_secret": "s"},
+			map[string]interface{}{"client_id": "id", "client_secret": "(sensitive)"}},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: redact = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlanKeepsSecretsInTheReply(t *testing.T) {
+	server := planKong(t)
+	defer server.Close()
+	plan := newPlanTransport(nil)
+	kc := kong.NewClient(server.URL+"/", &http.Client{Transport: plan})
+
+	created, err := kc.CreatePlugin(&kong.Plugin{Name: "ldap-auth", Config: map[string]interface{}{"password": "p"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Config["password"] != "p" {
+		t.Errorf("reply config = %v, want the password as it was sent", created.Config)
+	}
+	var out bytes.Buffer
+	if err := writePlan(plan, "json", &out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), `"p"`) || !strings.Contains(out.String(), "(sensitive)") {
+		t.Errorf("plan shows the password: %s", out.String())
+	}
+}
+
+func TestPlanPlaceholderIDs(t *testing.T) {
+	server := planKong(t)
+	defer server.Close()
+	plan := newPlanTransport(nil)
+	kc := kong.NewClient(server.URL+"/", &http.Client{Transport: plan})
+
+	service, err := kc.CreateService(&kong.Service{Name: "coredata", Host: "edgex-core-data"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.ID == "" || service.Name != "coredata" {
+		t.Fatalf("planned service = %+v, want a placeholder id and the name", service)
+	}
+	if _, err := kc.CreateRoute(service.ID, &kong.Route{Paths: []string{"/coredata"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kc.CreatePlugin(&kong.Plugin{Name: "jwt", ServiceID: service.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := kc.DeleteService(service.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := plan.output().Calls
+	if len(calls) != 4 {
+		t.Fatalf("planned %d calls, want 4", len(calls))
+	}
+	for _, call := range calls {
+		if strings.Contains(call.Path, "//") {
+			t.Errorf("%s %s: empty id in the path", call.Method, call.Path)
+		}
+	}
+	if want := "services/" + service.ID + "/routes"; !strings.HasPrefix(calls[1].Path, want) {
+		t.Errorf("route path = %s, want %s", calls[1].Path, want)
+	}
+	if calls[2].Fields["service_id"] != service.ID {
+		t.Errorf("plugin service_id = %v, want %s", calls[2].Fields["service_id"], service.ID)
+	}
+	if calls[3].Name != "coredata" {
+		t.Errorf("delete names %q, want the planned service coredata", calls[3].Name)
+	}
+}
@@ -17,19 +17,65 @@
 package main
 
 import (
-	"net/http"
+	"errors"
+	"fmt"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
 )
 
-func resetProxy(url string, client *http.Client) {
-	paths := []string{RoutesPath, ServicesPath, ConsumersPath, PluginsPath, CertificatesPath}
-	for _, p := range paths {
-		d, err := getIDListFromEndpoint(url, p, client)
+// resetProxy removes everything it can and returns an error when anything is left behind
+func resetProxy(kc *kong.Client) error {
+	failed := 0
+	count := func(err error) {
 		if err != nil {
 			lc.Error(err.Error())
-		} else {
-			for _, c := range d.Section {
-				deleteResource(c.ID, url, p, p, client)
-			}
+			failed++
 		}
 	}
+
+	routes, err := kc.ListRoutes()
+	count(err)
+	for _, r := range routes {
+		count(logDelete(kc.DeleteRoute(r.ID), r.ID, "routes"))
+	}
+
+	services, err := kc.ListServices()
+	count(err)
+	for _, s := range services {
+		count(logDelete(kc.DeleteService(s.ID), s.ID, "services"))
+	}
+
+	consumers, err := kc.ListConsumers()
+	count(err)
+	for _, c := range consumers {
+		count(logDelete(kc.DeleteConsumer(c.ID), c.ID, "consumers"))
+	}
+
+	plugins, err := kc.ListPlugins()
+	count(err)
+	for _, p := range plugins {
+		count(logDelete(kc.DeletePlugin(p.ID), p.ID, "plugins"))
+	}
+
+	certs, err := kc.ListCertificates()
+	count(err)
+	for _, c := range certs {
+		count(logDelete(kc.DeleteCertificate(c.ID), c.ID, "certificates"))
+	}
+
+	if failed > 0 {
+		s := fmt.Sprintf("Failed to reset %d parts of the reverse proxy, see the log for details.", failed)
+		lc.Error(s)
+		return errors.New(s)
+	}
+	return nil
+}
+
+func logDelete(err error, id string, endpoint string) error {
+	if err != nil {
+		s := fmt.Sprintf("Failed to delete %s at %s with error %s.", id, endpoint, err.Error())
+		return errors.New(s)
+	}
+	lc.Info(fmt.Sprintf("Successful to delete %s at %s.", id, endpoint))
+	return nil
 }
@@ -0,0 +1,214 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+type backoff struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     float64
+	timeout    time.Duration
+	attempts   int
+}
+
+func newBackoff(r retry) (backoff, error) {
+	b := backoff{
+		initial:    time.Second,
+		max:        30 * time.Second,
+		multiplier: 2,
+		jitter:     0.2,
+		timeout:    2 * time.Minute,
+		attempts:   3,
+	}
+	durations := []struct {
+		value  string
+		target *time.Duration
+	}{
+		{r.InitialInterval, &b.initial},
+		{r.MaxInterval, &b.max},
+		{r.Timeout, &b.timeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			s := fmt.Sprintf("Invalid duration %s in retry configuration.", d.value)
+			return b, errors.New(s)
+		}
+		*d.target = v
+	}
+	if r.Multiplier >= 1 {
+		b.multiplier = r.Multiplier
+	}
+	if r.Jitter > 0 && r.Jitter < 1 {
+		b.jitter = r.Jitter
+	}
+	if r.Attempts > 0 {
+		b.attempts = r.Attempts
+	}
+	return b, nil
+}
+
+// interval returns the exponential delay before the given retry, spread by +/- jitter so that
+// several containers started together do not hammer a dependency in lockstep
+func (b backoff) interval(attempt int) time.Duration {
+	d := float64(b.initial) * math.Pow(b.multiplier, float64(attempt))
+	if d > float64(b.max) {
+		d = float64(b.max)
+	}
+	d = d * (1 + b.jitter*(2*rand.Float64()-1))
+	return time.Duration(d)
+}
+
+// waitFor calls check until it succeeds or the overall timeout would be exceeded
+func waitFor(name string, b backoff, check func() error) error {
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		wait := b.interval(attempt)
+		if time.Since(start)+wait > b.timeout {
+			s := fmt.Sprintf("Gave up waiting for %s after %s with error %s.", name, time.Since(start).Round(time.Millisecond), err.Error())
+			return errors.New(s)
+		}
+		lc.Info(fmt.Sprintf("%s is not ready with error %s, retrying in %s.", name, err.Error(), wait.Round(time.Millisecond)))
+		time.Sleep(wait)
+	}
+}
+
+// retryTransport resends idempotent requests that failed or got a 5xx back, and any request
+// that could not connect and so never reached the server. Every attempt gets its own timeout,
+// so the waits in between do not eat into the time of the next attempt.
+type retryTransport struct {
+	next    http.RoundTripper
+	backoff backoff
+	timeout time.Duration
+}
+
+func newRetryTransport(next http.RoundTripper, b backoff, timeout time.Duration) *retryTransport {
+	return &retryTransport{next: next, backoff: b, timeout: timeout}
+}
+
+func (r *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		try := req
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, errors.New("request body can't be replayed for retry")
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			try = new(http.Request)
+			*try = *req
+			try.Body = body
+		}
+
+		resp, err := r.attempt(try)
+		if !r.retriable(req, resp, err) || attempt+1 >= r.backoff.attempts {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			err = errors.New(resp.Status)
+		}
+		wait := r.backoff.interval(attempt)
+		lc.Info(fmt.Sprintf("%s %s failed with error %s, retrying in %s.", req.Method, req.URL.Path, err.Error(), wait.Round(time.Millisecond)))
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// attempt sends the request once within the per attempt timeout, which stays in force until
+// the body of the response is closed
+func (r *retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if r.timeout <= 0 {
+		return r.next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), r.timeout)
+	resp, err := r.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retriable tells whether sending the request again is safe, requests that may create
+// something are only resent when they never left
+func (r *retryTransport) retriable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if isDialError(err) {
+		return true
+	}
+	if !isIdempotent(req.Method) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+func isDialError(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
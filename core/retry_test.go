@@ -0,0 +1,153 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testBackoff(t *testing.T, attempts int) backoff {
+	b, err := newBackoff(retry{InitialInterval: "10ms", MaxInterval: "50ms", Attempts: attempts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRetryTransportStatus(t *testing.T) {
+	tests := []struct {
+		method string
+		calls  int32
+	}{
+		{"GET", 3},
+		{"PUT", 3},
+		{"DELETE", 3},
+		{"POST", 1},
+		{"PATCH", 1},
+	}
+	for _, tt := range tests {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		c := &http.Client{Transport: newRetryTransport(http.DefaultTransport, testBackoff(t, 3), time.Second)}
+		req, _ := http.NewRequest(tt.method, srv.URL, strings.NewReader("body"))
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.method, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable || calls != tt.calls {
+			t.Errorf("%s: got %d after %d calls, want %d calls", tt.method, resp.StatusCode, calls, tt.calls)
+		}
+		srv.Close()
+	}
+}
+
+func TestRetryTransportReplaysBody(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write(b)
+	}))
+	defer srv.Close()
+	c := &http.Client{Transport: newRetryTransport(http.DefaultTransport, testBackoff(t, 3), time.Second)}
+	req, _ := http.NewRequest("PUT", srv.URL, strings.NewReader("hello"))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "hello" || calls != 3 {
+		t.Errorf("got %q after %d calls", body, calls)
+	}
+}
+
+func TestRetryTransportDialError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	c := &http.Client{Transport: newRetryTransport(http.DefaultTransport, testBackoff(t, 3), time.Second)}
+	start := time.Now()
+	_, err = c.Post("http://"+addr+"/", "text/plain", strings.NewReader("x"))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	// a POST that never connected is retried, so the backoff has been waited twice
+	if time.Since(start) < 20*time.Millisecond {
+		t.Errorf("POST was not retried after a dial error: %s", err)
+	}
+}
+
+func TestRetryTransportTimeoutPerAttempt(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	c := &http.Client{Transport: newRetryTransport(http.DefaultTransport, testBackoff(t, 3), 100*time.Millisecond)}
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("the third attempt should succeed within its own timeout: %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "ok" || calls != 3 {
+		t.Errorf("got %q after %d calls", body, calls)
+	}
+}
+
+func TestRetryTransportContextCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	b, _ := newBackoff(retry{InitialInterval: "10s", Attempts: 5})
+	c := &http.Client{Transport: newRetryTransport(http.DefaultTransport, b, time.Second)}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	start := time.Now()
+	_, err := c.Do(req.WithContext(ctx))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("the backoff ignored the cancelled context, took %s", time.Since(start))
+	}
+}
@@ -0,0 +1,245 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+// Every issued token gets a jwt credential of its own whose key is the jti of the token, so a
+// single token is revoked by deleting its credential. The revocation list remembers what was
+// revoked so that a jti is never issued again and a credential put back by hand is removed
+// on the next run.
+type revokedCredential struct {
+	Username  string    `json:"username"`
+	ID        string    `json:"credential_id"`
+	Key       string    `json:"key"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+var revocationMutex sync.Mutex
+
+type credentialNotFound struct {
+	kind    string
+	user    string
+	idOrKey string
+}
+
+func (e credentialNotFound) Error() string {
+	return fmt.Sprintf("Consumer %s has no %s credential with id or key %s.", e.user, e.kind, e.idOrKey)
+}
+
+func readRevocations(path string) ([]revokedCredential, error) {
+	revoked := []revokedCredential{}
+	err := readJSONFile(path, &revoked)
+	return revoked, err
+}
+
+func isRevoked(config *tomlConfig, jti string) (bool, error) {
+	revocationMutex.Lock()
+	defer revocationMutex.Unlock()
+	revoked, err := readRevocations(config.Revocation.List)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range revoked {
+		if r.Key == jti {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func listCredentials(kc *kong.Client, user string) ([]credentialListing, error) {
+	creds, err := kc.ListJWTCredentials(user)
+	if err != nil {
+		s := fmt.Sprintf("Failed to read jwt credentials of consumer %s with error %s.", user, err.Error())
+		lc.Error(s)
+		return nil, errors.New(s)
+	}
+	listing := []credentialListing{}
+	for _, c := range creds {
+		listing = append(listing, credentialListing{
+			ID:        c.ID,
+			Key:       c.Key,
+			Algorithm: c.Algorithm,
+			CreatedAt: formatKongTime(c.CreatedAt),
+		})
+	}
+	return listing, nil
+}
+
+func writeCredentials(creds []credentialListing, format string, w io.Writer) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(creds)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "key", "algorithm", "created_at"})
+		for _, c := range creds {
+			cw.Write([]string{c.ID, c.Key, c.Algorithm, c.CreatedAt})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "text", "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tKEY\tALGORITHM\tCREATED")
+		for _, c := range creds {
+			fmt.Fprintln(tw, strings.Join([]string{c.ID, c.Key, c.Algorithm, c.CreatedAt}, "\t"))
+		}
+		return tw.Flush()
+	}
+	s := fmt.Sprintf("Unsupported credential list format %s.", format)
+	return errors.New(s)
+}
+
+// revokeCredential deletes the credential with the given id or key, which is the jti of the
+// token it was issued for, and adds it to the revocation list
+func revokeCredential(config *tomlConfig, kc *kong.Client, user string, idOrKey string, dryRun bool) error {
+	creds, err := kc.ListJWTCredentials(user)
+	if err != nil {
+		s := fmt.Sprintf("Failed to read jwt credentials of consumer %s with error %s.", user, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	var cred *kong.JWTCredential
+	for i := range creds {
+		if creds[i].ID == idOrKey || creds[i].Key == idOrKey {
+			cred = &creds[i]
+			break
+		}
+	}
+	if cred == nil {
+		err := credentialNotFound{kind: JWTPlugin, user: user, idOrKey: idOrKey}
+		lc.Error(err.Error())
+		return err
+	}
+
+	if err := kc.DeleteJWTCredential(user, cred.ID); err != nil {
+		s := fmt.Sprintf("Failed to revoke jwt credential %s of consumer %s with error %s.", cred.Key, user, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	lc.Info(fmt.Sprintf("Successful to revoke jwt credential %s of consumer %s.", cred.Key, user))
+	if dryRun {
+		return nil
+	}
+
+	revocationMutex.Lock()
+	defer revocationMutex.Unlock()
+	revoked, err := readRevocations(config.Revocation.List)
+	if err != nil {
+		s := fmt.Sprintf("Failed to read revocation list %s with error %s.", config.Revocation.List, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	revoked = append(revoked, revokedCredential{
+		Username:  user,
+		ID:        cred.ID,
+		Key:       cred.Key,
+		RevokedAt: time.Now().UTC(),
+	})
+	if err := writeJSONFile(config.Revocation.List, revoked); err != nil {
+		s := fmt.Sprintf("Failed to record revocation of %s in %s with error %s.", cred.Key, config.Revocation.List, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	return nil
+}
+
+// enforceRevocations removes revoked credentials that have reappeared in the reverse proxy,
+// e.g. restored from a database backup
+func enforceRevocations(config *tomlConfig, kc *kong.Client) error {
+	revocationMutex.Lock()
+	revoked, err := readRevocations(config.Revocation.List)
+	revocationMutex.Unlock()
+	if err != nil {
+		s := fmt.Sprintf("Failed to read revocation list %s with error %s.", config.Revocation.List, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+
+	byUser := map[string][]string{}
+	for _, r := range revoked {
+		byUser[r.Username] = append(byUser[r.Username], r.Key)
+	}
+	for user, keys := range byUser {
+		creds, err := kc.ListJWTCredentials(user)
+		if kong.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			lc.Error(fmt.Sprintf("Failed to read jwt credentials of consumer %s with error %s.", user, err.Error()))
+			continue
+		}
+		for _, c := range creds {
+			if !containsString(keys, c.Key) {
+				continue
+			}
+			if err := kc.DeleteJWTCredential(user, c.ID); err != nil && !kong.IsNotFound(err) {
+				lc.Error(fmt.Sprintf("Failed to remove revoked jwt credential %s of consumer %s with error %s.", c.Key, user, err.Error()))
+				continue
+			}
+			lc.Info(fmt.Sprintf("Successful to remove revoked jwt credential %s of consumer %s.", c.Key, user))
+		}
+	}
+	return nil
+}
+
+func writeRevocations(config *tomlConfig, format string, w io.Writer) error {
+	revocationMutex.Lock()
+	revoked, err := readRevocations(config.Revocation.List)
+	revocationMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(revoked)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"username", "credential_id", "key", "revoked_at"})
+		for _, r := range revoked {
+			cw.Write([]string{r.Username, r.ID, r.Key, r.RevokedAt.Format(time.RFC3339)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "text", "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "USERNAME\tCREDENTIAL ID\tKEY\tREVOKED")
+		for _, r := range revoked {
+			fmt.Fprintln(tw, strings.Join([]string{r.Username, r.ID, r.Key, r.RevokedAt.Format(time.RFC3339)}, "\t"))
+		}
+		return tw.Flush()
+	}
+	s := fmt.Sprintf("Unsupported revocation list format %s.", format)
+	return errors.New(s)
+}
@@ -0,0 +1,121 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+// listFlags collects repeated or comma separated values such as --role=a,b --role=c
+type listFlags []string
+
+func (l *listFlags) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlags) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// aclGroups returns the roles that may call the service, a role listing "*" may call every
+// service including the admin loopback. Without any role configured nil is returned and the
+// services stay open to every authenticated consumer.
+func aclGroups(config *tomlConfig, service string) []string {
+	if len(config.Roles) == 0 {
+		return nil
+	}
+	groups := []string{}
+	for name, r := range config.Roles {
+		if containsString(r.Services, "*") || containsString(r.Services, service) {
+			groups = append(groups, name)
+		}
+	}
+	if len(groups) == 0 {
+		// the acl plugin needs at least one group, use one no consumer is ever put in
+		return []string{NoRoleGroup}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// a public or ldap-auth service has no consumer to check the groups of, so it gets no acl
+// plugin either
+func aclPlugin(config *tomlConfig, service string) *kong.Plugin {
+	if auth := authentication(config, service); auth == NoAuth || auth == LDAPAuthPlugin {
+		return nil
+	}
+	groups := aclGroups(config, service)
+	if groups == nil {
+		return nil
+	}
+	return &kong.Plugin{Name: ACLPlugin, Config: map[string]interface{}{"whitelist": groups}}
+}
+
+func initACLForService(config *tomlConfig, kc *kong.Client, name string) error {
+	aclParams := aclPlugin(config, name)
+	if aclParams == nil {
+		return nil
+	}
+
+	_, err := kc.CreateServicePlugin(name, aclParams)
+	if err != nil && !kong.IsConflict(err) {
+		s := fmt.Sprintf("Failed to set up access control for service %s with error %s.", name, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+	lc.Info(fmt.Sprintf("Successful to set up access control for service %s with groups %s.", name, strings.Join(aclGroups(config, name), ",")))
+	return nil
+}
+
+func validRoles(config *tomlConfig, roles []string) error {
+	for _, r := range roles {
+		if _, ok := config.Roles[r]; !ok {
+			s := fmt.Sprintf("Role %s is not defined in the [roles] section.", r)
+			return errors.New(s)
+		}
+	}
+	return nil
+}
+
+// addConsumerGroups puts the consumer into the acl group of every role and into the extra groups
+func addConsumerGroups(config *tomlConfig, kc *kong.Client, user string, roles []string, groups []string) error {
+	if err := validRoles(config, roles); err != nil {
+		lc.Error(err.Error())
+		return err
+	}
+
+	for _, g := range append(append([]string{}, roles...), groups...) {
+		_, err := kc.CreateACL(user, g)
+		if err != nil && !kong.IsConflict(err) {
+			s := fmt.Sprintf("Failed to add consumer %s to group %s with error %s.", user, g, err.Error())
+			lc.Error(s)
+			return errors.New(s)
+		}
+		lc.Info(fmt.Sprintf("Successful to add consumer %s to group %s.", user, g))
+	}
+	return nil
+}
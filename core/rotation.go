@@ -0,0 +1,229 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+// rotationRecord is one rotation in the ledger. The credentials the consumer had before the
+// rotation are retired once the grace period has passed.
+type rotationRecord struct {
+	Username    string              `json:"username"`
+	RotatedAt   time.Time           `json:"rotated_at"`
+	NewKey      string              `json:"new_key"`
+	Algorithm   string              `json:"algorithm"`
+	Retired     []retiredCredential `json:"retired"`
+	RetireAfter time.Time           `json:"retire_after"`
+	CompletedAt *time.Time          `json:"completed_at,omitempty"`
+}
+
+type retiredCredential struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+}
+
+// the ledger is read and written by the rotate command, the daemon and the reaper
+var ledgerMutex sync.Mutex
+
+func readLedger(path string) ([]rotationRecord, error) {
+	records := []rotationRecord{}
+	err := readJSONFile(path, &records)
+	return records, err
+}
+
+func writeLedger(path string, records []rotationRecord) error {
+	return writeJSONFile(path, records)
+}
+
+// readJSONFile leaves v untouched when the file does not exist yet or is empty
+func readJSONFile(path string, v interface{}) error {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write next to the file and rename so a crash never leaves half a file behind
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func rotationGrace(config *tomlConfig, grace string) (time.Duration, error) {
+	if grace == "" {
+		grace = config.Rotation.Grace
+	}
+	if grace == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(grace)
+	if err != nil || d < 0 {
+		s := fmt.Sprintf("Invalid rotation grace period %s.", grace)
+		return 0, errors.New(s)
+	}
+	return d, nil
+}
+
+// rotateJWTForConsumer issues a new credential and token for an existing consumer and records
+// the credentials it had so far, which keep working until the grace period has passed. As every
+// token has a credential of its own, credential selects the one to retire by id or key (the jti
+// of its token); when it is empty all of them are retired, i.e. every token of the consumer.
+// With a transit signer the transit key is rotated too, old tokens stay valid through the public
+// key of the old credential.
+func rotateJWTForConsumer(config *tomlConfig, user string, credential string, kc *kong.Client, opts tokenOptions, grace time.Duration, dryRun bool) (*issuedJWT, error) {
+	current, err := kc.ListJWTCredentials(user)
+	if err != nil {
+		s := fmt.Sprintf("Failed to read jwt credentials of consumer %s with error %s.", user, err.Error())
+		lc.Error(s)
+		return nil, errors.New(s)
+	}
+	if credential != "" {
+		selected := []kong.JWTCredential{}
+		for _, c := range current {
+			if c.ID == credential || c.Key == credential {
+				selected = append(selected, c)
+			}
+		}
+		if len(selected) == 0 {
+			err := credentialNotFound{kind: JWTPlugin, user: user, idOrKey: credential}
+			lc.Error(err.Error())
+			return nil, err
+		}
+		current = selected
+	}
+
+	if opts.Signer != nil && opts.Algorithm != "HS256" {
+		if err := opts.Signer.rotateKey(user); err != nil {
+			return nil, err
+		}
+	}
+	issued, err := createJWTForConsumer(user, kc, EdgeXService, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	record := rotationRecord{
+		Username:    user,
+		RotatedAt:   now,
+		NewKey:      issued.Credential.Key,
+		Algorithm:   opts.Algorithm,
+		Retired:     []retiredCredential{},
+		RetireAfter: now.Add(grace),
+	}
+	for _, c := range current {
+		record.Retired = append(record.Retired, retiredCredential{ID: c.ID, Key: c.Key})
+	}
+	lc.Info(fmt.Sprintf("Successful to rotate jwt credential of consumer %s, %d old credentials are retired after %s.", user, len(record.Retired), record.RetireAfter.Format(time.RFC3339)))
+	if dryRun {
+		return issued, nil
+	}
+
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	records, err := readLedger(config.Rotation.Ledger)
+	if err != nil {
+		s := fmt.Sprintf("Failed to read rotation ledger %s with error %s.", config.Rotation.Ledger, err.Error())
+		lc.Error(s)
+		return issued, errors.New(s)
+	}
+	if err := writeLedger(config.Rotation.Ledger, append(records, record)); err != nil {
+		s := fmt.Sprintf("Failed to record rotation of consumer %s in %s with error %s.", user, config.Rotation.Ledger, err.Error())
+		lc.Error(s)
+		return issued, errors.New(s)
+	}
+	return issued, nil
+}
+
+// reapRotations deletes the credentials of every rotation whose grace period has passed. A
+// credential or consumer that is already gone counts as deleted.
+func reapRotations(config *tomlConfig, kc *kong.Client, dryRun bool) error {
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	records, err := readLedger(config.Rotation.Ledger)
+	if err != nil {
+		s := fmt.Sprintf("Failed to read rotation ledger %s with error %s.", config.Rotation.Ledger, err.Error())
+		lc.Error(s)
+		return errors.New(s)
+	}
+
+	now := time.Now().UTC()
+	changed := false
+	for i := range records {
+		r := &records[i]
+		if r.CompletedAt != nil || now.Before(r.RetireAfter) {
+			continue
+		}
+		done := true
+		for _, c := range r.Retired {
+			err := kc.DeleteJWTCredential(r.Username, c.ID)
+			if err != nil && !kong.IsNotFound(err) {
+				lc.Error(fmt.Sprintf("Failed to retire jwt credential %s of consumer %s with error %s.", c.Key, r.Username, err.Error()))
+				done = false
+				continue
+			}
+			lc.Info(fmt.Sprintf("Successful to retire jwt credential %s of consumer %s.", c.Key, r.Username))
+		}
+		if done {
+			completed := now
+			r.CompletedAt = &completed
+			changed = true
+		}
+	}
+	if !changed || dryRun {
+		return nil
+	}
+	return writeLedger(config.Rotation.Ledger, records)
+}
+
+// reapPeriodically runs the reaper and enforces the revocation list until stop is closed
+func reapPeriodically(config *tomlConfig, kc *kong.Client, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			reapRotations(config, kc, false)
+			enforceRevocations(config, kc)
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+// jwtCredentials is a Kong whose consumer guest has a jwt credential for each of two tokens
+func jwtCredentials(t *testing.T, created *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/consumers/guest/jwt/":
+			w.Write([]byte(`{"data": [{"id": "cred-1", "key": "jti-1"}, {"id": "cred-2", "key": "jti-2"}]}`))
+		case r.Method == "POST" && r.URL.Path == "/consumers/guest/jwt/":
+			*created++
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(kong.JWTCredential{ID: "cred-3", Key: "jti-3", Secret: "secret"})
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestRotateRetiresSelectedCredential(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		credential string
+		retired    []retiredCredential
+	}{
+		{"", []retiredCredential{{ID: "cred-1", Key: "jti-1"}, {ID: "cred-2", Key: "jti-2"}}},
+		{"jti-2", []retiredCredential{{ID: "cred-2", Key: "jti-2"}}},
+		{"cred-1", []retiredCredential{{ID: "cred-1", Key: "jti-1"}}},
+	}
+	for i, tt := range tests {
+		created := 0
+		server := jwtCredentials(t, &created)
+		config := &tomlConfig{Rotation: rotation{Ledger: filepath.Join(dir, "ledger.json")}}
+		_, err := rotateJWTForConsumer(config, "guest", tt.credential, kong.NewClient(server.URL+"/", &http.Client{}), tokenOptions{Algorithm: "HS256"}, time.Hour, false)
+		server.Close()
+		if err != nil {
+			t.Errorf("rotate %q: %s", tt.credential, err.Error())
+			continue
+		}
+		records, err := readLedger(config.Rotation.Ledger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != i+1 || created != 1 {
+			t.Fatalf("rotate %q: %d records and %d new credentials", tt.credential, len(records), created)
+		}
+		if r := records[i]; r.NewKey != "jti-3" || !reflect.DeepEqual(r.Retired, tt.retired) {
+			t.Errorf("rotate %q recorded %s retiring %v, want jti-3 retiring %v", tt.credential, r.NewKey, r.Retired, tt.retired)
+		}
+	}
+
+	created := 0
+	server := jwtCredentials(t, &created)
+	defer server.Close()
+	config := &tomlConfig{Rotation: rotation{Ledger: filepath.Join(dir, "ledger.json")}}
+	_, err = rotateJWTForConsumer(config, "guest", "jti-9", kong.NewClient(server.URL+"/", &http.Client{}), tokenOptions{Algorithm: "HS256"}, time.Hour, false)
+	if _, ok := err.(credentialNotFound); !ok || created != 0 {
+		t.Errorf("rotating an unknown credential = %v with %d new credentials, want credentialNotFound and none", err, created)
+	}
+}
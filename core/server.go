@@ -0,0 +1,584 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+const apiPrefix = "/api/v1/"
+
+// defaultServerHost keeps the REST API off other interfaces unless [server] host says otherwise
+const defaultServerHost = "127.0.0.1"
+
+// the [kongadmin] password shipped in res/configuration.toml
+const shippedAdminPassword = "changeme"
+
+type apiServer struct {
+	config        *tomlConfig
+	kc            *kong.Client
+	secretBaseURL string
+	client        *http.Client
+	probe         *http.Client
+	signer        *transitSigner
+	keyStore      *apiKeyStore
+	credStore     *credentialStore
+	mutex         sync.Mutex
+}
+
+type consumerRequest struct {
+	Username string   `json:"username"`
+	CustomID string   `json:"custom_id"`
+	Roles    []string `json:"roles"`
+	Groups   []string `json:"groups"`
+}
+
+type tokenRequest struct {
+	Algorithm string            `json:"algorithm"`
+	TTL       string            `json:"ttl"`
+	NotBefore string            `json:"nbf"`
+	Audience  string            `json:"aud"`
+	ID        string            `json:"jti"`
+	Claims    map[string]string `json:"claims"`
+	Grace     string            `json:"grace"`
+	// Credential selects the jwt credential a rotation retires, all of them when empty
+	Credential string `json:"credential"`
+}
+
+type tokenResponse struct {
+	Username   string `json:"username"`
+	Token      string `json:"token"`
+	PrivateKey string `json:"private_key,omitempty"`
+}
+
+type oauth2AppRequest struct {
+	Name        string `json:"name"`
+	RedirectURI string `json:"redirect_uri"`
+}
+
+type apiKeyResponse struct {
+	Username string `json:"username"`
+	ID       string `json:"id"`
+	Key      string `json:"key"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// runServer keeps the security service running and exposes account management and the
+// init/reset/sync procedures over HTTP until SIGTERM or SIGINT is received.
+func runServer(config *tomlConfig, kc *kong.Client, secretBaseURL string, client *http.Client, probe *http.Client) error {
+	if err := validServerCredentials(config); err != nil {
+		lc.Error(err.Error())
+		return err
+	}
+	cert, key, err := getCertKeyPair(config, secretBaseURL, client)
+	if err != nil {
+		return err
+	}
+	pair, err := tls.X509KeyPair([]byte(cert), []byte(key))
+	if err != nil {
+		errStr := fmt.Sprintf("Invalid certificate from %s with error %s.", config.SecretService.CertPath, err.Error())
+		lc.Error(errStr)
+		return errors.New(errStr)
+	}
+
+	signer, err := newTransitSigner(config, secretBaseURL, client, false)
+	if err != nil {
+		return err
+	}
+	keyStore, err := newAPIKeyStore(config, secretBaseURL, client)
+	if err != nil {
+		return err
+	}
+	credStore, err := newCredentialStore(config, secretBaseURL, client)
+	if err != nil {
+		return err
+	}
+	s := &apiServer{
+		config:        config,
+		kc:            kc,
+		secretBaseURL: secretBaseURL,
+		client:        client,
+		probe:         probe,
+		signer:        signer,
+		keyStore:      keyStore,
+		credStore:     credStore,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.liveness)
+	mux.HandleFunc("/readyz", s.readiness)
+	mux.HandleFunc(apiPrefix+"consumers", s.authenticated(s.consumers))
+	mux.HandleFunc(apiPrefix+"consumers/", s.authenticated(s.consumer))
+	mux.HandleFunc(apiPrefix+"revocations", s.authenticated(s.revocations))
+	mux.HandleFunc(apiPrefix+"init", s.authenticated(s.operation(s.runInit)))
+	mux.HandleFunc(apiPrefix+"reset", s.authenticated(s.operation(s.runReset)))
+	mux.HandleFunc(apiPrefix+"sync", s.authenticated(s.operation(s.runSync)))
+
+	host := config.Server.Host
+	if host == "" {
+		host = defaultServerHost
+	}
+	srv := &http.Server{
+		Addr:    fmt.Sprintf("%s:%s", host, config.Server.Port),
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{pair},
+			MinVersion:   tls.VersionTLS12,
+		},
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 60 * time.Second,
+	}
+
+	interval := 5 * time.Minute
+	if config.Rotation.ReapInterval != "" {
+		interval, err = time.ParseDuration(config.Rotation.ReapInterval)
+		if err != nil || interval <= 0 {
+			errStr := fmt.Sprintf("Invalid rotation reap interval %s.", config.Rotation.ReapInterval)
+			lc.Error(errStr)
+			return errors.New(errStr)
+		}
+	}
+	reaperDone := make(chan struct{})
+	defer close(reaperDone)
+	go reapPeriodically(config, kc, interval, reaperDone)
+
+	if len(config.JWKS.Issuers) > 0 {
+		refresh, err := jwksRefreshInterval(config)
+		if err != nil {
+			lc.Error(err.Error())
+			return err
+		}
+		go refreshIssuersPeriodically(config, kc, refresh, reaperDone)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	failed := make(chan error, 1)
+	go func() {
+		lc.Info(fmt.Sprintf("Security service is listening on %s.", srv.Addr))
+		if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			failed <- err
+		}
+	}()
+
+	select {
+	case err := <-failed:
+		return err
+	case sig := <-stop:
+		lc.Info(fmt.Sprintf("Received %s, shutting down the security service.", sig))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// validServerCredentials refuses to serve the API behind an empty or the shipped password
+func validServerCredentials(config *tomlConfig) error {
+	if config.KongAdmin.UserName == "" || config.KongAdmin.Password == "" {
+		return errors.New("The REST API needs the username and password in [kongadmin] to be set.")
+	}
+	if config.KongAdmin.Password == shippedAdminPassword {
+		return errors.New("The REST API does not run with the shipped [kongadmin] password, please change it.")
+	}
+	return nil
+}
+
+func (s *apiServer) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || !secureEquals(user, s.config.KongAdmin.UserName) || !secureEquals(password, s.config.KongAdmin.Password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="edgexsecurity"`)
+			writeError(w, http.StatusUnauthorized, errors.New("authentication required"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func secureEquals(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (s *apiServer) liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+func (s *apiServer) readiness(w http.ResponseWriter, r *http.Request) {
+	err := checkProxyStatus(kong.NewClient(s.kc.BaseURL(), s.probe))
+	if err == nil {
+		err = checkSecretServiceStatus(s.secretBaseURL+s.config.SecretService.HealthcheckPath, s.probe, s.config.SecretService.StandbyOK)
+	}
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// GET lists consumers, POST creates one
+func (s *apiServer) consumers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		consumers, err := s.kc.ListConsumers()
+		if err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, consumers)
+	case "POST":
+		body := consumerRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Username == "" {
+			writeError(w, http.StatusBadRequest, errors.New("a json body with username is required"))
+			return
+		}
+		if err := validUsername(s.config, body.Username); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := validRoles(s.config, body.Roles); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := createConsumer(s.config, body.Username, body.CustomID, s.kc, EdgeXService); err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		if err := addConsumerGroups(s.config, s.kc, body.Username, body.Roles, body.Groups); err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		consumer, err := s.kc.GetConsumer(body.Username)
+		if err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, consumer)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// handles consumers/{name}, consumers/{name}/jwt, consumers/{name}/jwt/{id}, consumers/{name}/rotate,
+// consumers/{name}/key-auth, consumers/{name}/key-auth/{id}, consumers/{name}/oauth2,
+// consumers/{name}/oauth2/{client id} and consumers/{name}/token
+func (s *apiServer) consumer(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix+"consumers/"), "/"), "/")
+	name := parts[0]
+	if name == "" {
+		// without a name the calls below would land on the consumer collection of kong
+		writeError(w, http.StatusNotFound, errors.New("missing consumer name"))
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == "GET":
+		consumer, err := s.kc.GetConsumer(name)
+		if err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, consumer)
+	case len(parts) == 1 && r.Method == "DELETE":
+		if err := s.kc.DeleteConsumer(name); err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		lc.Info(fmt.Sprintf("Successful to delete consumer %s.", name))
+		if s.credStore != nil {
+			if err := s.credStore.clear(name); err != nil {
+				writeError(w, http.StatusBadGateway, err)
+				return
+			}
+		}
+		if s.keyStore != nil {
+			if err := s.keyStore.clear(name); err != nil {
+				writeError(w, http.StatusBadGateway, err)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[1] == "token" && r.Method == "GET":
+		stored, err := getStoredToken(s.config, s.credStore, name)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, stored)
+	case len(parts) == 2 && parts[1] == "jwt" && r.Method == "GET":
+		creds, err := s.kc.ListJWTCredentials(name)
+		if err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		for i := range creds {
+			creds[i].Secret = ""
+		}
+		writeJSON(w, http.StatusOK, creds)
+	case len(parts) == 2 && parts[1] == "jwt" && r.Method == "POST":
+		body := tokenRequest{}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		opts, err := newTokenOptions(s.config, body.Algorithm, body.TTL, body.NotBefore, body.Audience, body.ID, body.Claims)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		opts.Signer = s.signer
+		if _, err := s.kc.GetConsumer(name); err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		issued, err := createJWTForConsumer(name, s.kc, EdgeXService, opts)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		if !s.storeCredentials(w, name, &consumerCredentials{JWT: issued}) {
+			return
+		}
+		writeJSON(w, http.StatusCreated, tokenResponse{Username: name, Token: issued.Token, PrivateKey: issued.PrivateKey})
+	case len(parts) == 2 && parts[1] == "rotate" && r.Method == "POST":
+		body := tokenRequest{}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		opts, err := newTokenOptions(s.config, body.Algorithm, body.TTL, body.NotBefore, body.Audience, body.ID, body.Claims)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		opts.Signer = s.signer
+		grace, err := rotationGrace(s.config, body.Grace)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if _, err := s.kc.GetConsumer(name); err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		issued, err := rotateJWTForConsumer(s.config, name, body.Credential, s.kc, opts, grace, false)
+		if _, ok := err.(credentialNotFound); ok {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		if s.credStore != nil {
+			// the old credentials are already on their way out, so the new one stays
+			if err := s.credStore.save(name, jwtCredentialsOf(name, issued)); err != nil {
+				errStr := fmt.Sprintf("The new jwt of consumer %s could not be stored, please rotate again once the secret service is back.", name)
+				lc.Error(errStr)
+				writeError(w, http.StatusBadGateway, errors.New(errStr))
+				return
+			}
+		}
+		writeJSON(w, http.StatusCreated, tokenResponse{Username: name, Token: issued.Token, PrivateKey: issued.PrivateKey})
+	case len(parts) == 3 && parts[1] == "jwt" && r.Method == "DELETE":
+		if _, err := s.kc.GetConsumer(name); err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		if err := revokeCredential(s.config, s.kc, name, parts[2], false); err != nil {
+			status := http.StatusBadGateway
+			if _, ok := err.(credentialNotFound); ok {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[1] == KeyAuthPlugin && r.Method == "GET":
+		keys, err := listAPIKeys(s.kc, name)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, keys)
+	case len(parts) == 2 && parts[1] == KeyAuthPlugin && r.Method == "POST":
+		if _, err := s.kc.GetConsumer(name); err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		cred, err := createAPIKey(s.kc, name, s.keyStore)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		lc.Info(fmt.Sprintf("Successful to create api key %s for consumer %s.", cred.ID, name))
+		if !s.storeCredentials(w, name, &consumerCredentials{KeyAuth: cred}) {
+			return
+		}
+		writeJSON(w, http.StatusCreated, apiKeyResponse{Username: name, ID: cred.ID, Key: cred.Key})
+	case len(parts) == 3 && parts[1] == KeyAuthPlugin && r.Method == "DELETE":
+		if _, err := s.kc.GetConsumer(name); err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		if err := revokeAPIKey(s.kc, name, parts[2], s.keyStore); err != nil {
+			status := http.StatusBadGateway
+			if _, ok := err.(credentialNotFound); ok {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[1] == OAuth2Plugin && r.Method == "GET":
+		apps, err := listOAuth2Apps(s.kc, name)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, apps)
+	case len(parts) == 2 && parts[1] == OAuth2Plugin && r.Method == "POST":
+		body := oauth2AppRequest{}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if _, err := s.kc.GetConsumer(name); err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		app, err := createOAuth2App(s.kc, name, body.Name, oauth2RedirectURI(s.config, body.RedirectURI))
+		if err != nil {
+			writeError(w, kongStatus(err), err)
+			return
+		}
+		lc.Info(fmt.Sprintf("Successful to create oauth2 application %s for consumer %s.", app.ClientID, name))
+		if !s.storeCredentials(w, name, &consumerCredentials{OAuth2: app}) {
+			return
+		}
+		writeJSON(w, http.StatusCreated, app)
+	case len(parts) == 3 && parts[1] == OAuth2Plugin && r.Method == "DELETE":
+		if err := revokeOAuth2App(s.kc, name, parts[2]); err != nil {
+			status := http.StatusBadGateway
+			if _, ok := err.(credentialNotFound); ok {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// storeCredentials keeps the issued credentials in the secret service when configured and
+// answers with an error when that fails, after removing them from the reverse proxy again
+func (s *apiServer) storeCredentials(w http.ResponseWriter, user string, creds *consumerCredentials) bool {
+	if s.credStore == nil {
+		return true
+	}
+	if err := saveOrRollback(s.credStore, s.kc, user, creds, s.keyStore); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return false
+	}
+	return true
+}
+
+func (s *apiServer) revocations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	revocationMutex.Lock()
+	revoked, err := readRevocations(s.config.Revocation.List)
+	revocationMutex.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, revoked)
+}
+
+// init, reset and sync are serialized and only accept POST
+func (s *apiServer) operation(run func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if err := run(); err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "done"})
+	}
+}
+
+func (s *apiServer) runInit() error {
+	return initSecurityServices(s.config, s.kc, s.secretBaseURL, s.client)
+}
+
+func (s *apiServer) runReset() error {
+	return resetProxy(s.kc)
+}
+
+func (s *apiServer) runSync() error {
+	return syncProxy(s.config, s.kc, s.secretBaseURL, s.client)
+}
+
+func kongStatus(err error) int {
+	code := kong.StatusCode(err)
+	if code >= 400 && code < 500 {
+		return code
+	}
+	return http.StatusBadGateway
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
@@ -17,26 +17,28 @@
 package main
 
 import (
-	"errors"
-	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"testing"
 
-	"github.com/dghubble/sling"
+	"github.com/edgexfoundry/edgexsecurity/kong"
 )
 
-func deleteResource(id string, url string, path string, endpoint string, c *http.Client) error {
-	req, err := sling.New().Base(url).Path(path).Delete(id).Request()
-	resp, err := c.Do(req)
-	if err != nil {
-		s := fmt.Sprintf("Failed to delete %s at %s with error %s.", id, endpoint, err.Error())
-		lc.Error(s)
-		return errors.New(s)
-	}
-	if resp.StatusCode == 200 || resp.StatusCode == 201 || resp.StatusCode == 204 {
-		lc.Info(fmt.Sprintf("Successful to delete %s at %s.", id, endpoint))
-		return nil
+func TestConsumerWithoutName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s %s forwarded to kong", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	s := &apiServer{kc: kong.NewClient(server.URL+"/", &http.Client{})}
+
+	for _, method := range []string{"GET", "DELETE", "PUT", "POST"} {
+		for _, path := range []string{apiPrefix + "consumers/", apiPrefix + "consumers//"} {
+			w := httptest.NewRecorder()
+			s.consumer(w, httptest.NewRequest(method, path, nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s %s = %d, want %d", method, path, w.Code, http.StatusNotFound)
+			}
+		}
 	}
-	s := fmt.Sprintf("Failed to delete %s at %s with errocode %d.", id, endpoint, resp.StatusCode)
-	lc.Error(s)
-	return errors.New(s)
 }
@@ -0,0 +1,113 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const rsaKeyBits = 2048
+
+var signingMethods = map[string]jwt.SigningMethod{
+	"HS256": jwt.SigningMethodHS256,
+	"RS256": jwt.SigningMethodRS256,
+	"ES256": jwt.SigningMethodES256,
+}
+
+// signingKey is a key pair generated for an asymmetric jwt credential. Only the public half
+// is registered in the reverse proxy, the private half is handed to the user.
+type signingKey struct {
+	private    interface{}
+	publicPEM  string
+	privatePEM string
+}
+
+func validAlgorithm(alg string) error {
+	if _, ok := signingMethods[alg]; !ok {
+		s := fmt.Sprintf("Unsupported jwt algorithm %s, expected HS256, RS256 or ES256.", alg)
+		return errors.New(s)
+	}
+	return nil
+}
+
+func generateSigningKey(alg string) (*signingKey, error) {
+	var private interface{}
+	var public interface{}
+	var block *pem.Block
+
+	switch alg {
+	case "RS256":
+		k, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		private, public = k, &k.PublicKey
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+	case "ES256":
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		private, public = k, &k.PublicKey
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	default:
+		s := fmt.Sprintf("No key pair is needed for jwt algorithm %s.", alg)
+		return nil, errors.New(s)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	return &signingKey{
+		private:    private,
+		publicPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		privatePEM: string(pem.EncodeToMemory(block)),
+	}, nil
+}
+
+// the private key is only readable by the owner when written to a file
+func outputPrivateKey(user string, privatePEM string, path string) error {
+	if path == "" {
+		fmt.Println(fmt.Sprintf("The private key for user %s is:\n%s", user, privatePEM))
+		return nil
+	}
+	if err := ioutil.WriteFile(path, []byte(privatePEM), 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of a file that already exists
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+	lc.Info(fmt.Sprintf("Successful to write the private key for user %s to %s.", user, path))
+	return nil
+}
@@ -0,0 +1,483 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+type syncChange struct {
+	Action string
+	Object string
+	Name   string
+	apply  func(kc *kong.Client) error
+}
+
+type kongState struct {
+	services []kong.Service
+	routes   []kong.Route
+	plugins  []kong.Plugin
+	certs    []kong.Certificate
+}
+
+type desiredService struct {
+	service *kong.Service
+	route   *kong.Route
+	auth    string
+}
+
+// syncProxy converges the services, routes, plugins and certificate owned by this tool
+// to what configuration.toml describes. Objects created by anyone else are left untouched.
+func syncProxy(config *tomlConfig, kc *kong.Client, secretBaseURL string, c *http.Client) error {
+	state, err := readKongState(kc)
+	if err != nil {
+		return err
+	}
+
+	cert, key, err := getCertKeyPair(config, secretBaseURL, c)
+	if err != nil {
+		lc.Error(fmt.Sprintf("Failed to retrieve certificate, skipping certificate sync with error %s.", err.Error()))
+	}
+
+	changes, err := diffKongState(config, state, cert, key)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		lc.Info("Reverse proxy is already in sync with the configuration.")
+		return nil
+	}
+	for _, ch := range changes {
+		if err := ch.apply(kc); err != nil {
+			s := fmt.Sprintf("Failed to %s %s %s with error %s.", ch.Action, ch.Object, ch.Name, err.Error())
+			lc.Error(s)
+			return errors.New(s)
+		}
+		lc.Info(fmt.Sprintf("Successful to %s %s %s.", ch.Action, ch.Object, ch.Name))
+	}
+	lc.Info(fmt.Sprintf("Finishing sync for reverse proxy with %d changes.", len(changes)))
+	return nil
+}
+
+func readKongState(kc *kong.Client) (kongState, error) {
+	state := kongState{}
+	var err error
+
+	if state.services, err = kc.ListServices(); err != nil {
+		return state, err
+	}
+	if state.routes, err = kc.ListRoutes(); err != nil {
+		return state, err
+	}
+	if state.plugins, err = kc.ListPlugins(); err != nil {
+		return state, err
+	}
+	if state.certs, err = kc.ListCertificates(); err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+func desiredServices(config *tomlConfig) ([]desiredService, error) {
+	if err := validAuthentication(config); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range config.EdgexServices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	desired := []desiredService{}
+	for _, name := range names {
+		service := config.EdgexServices[name]
+		s, err := kongServiceFromConfig(service.Name, service.Host, service.Port, service.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		desired = append(desired, desiredService{
+			service: s,
+			route: &kong.Route{
+				Paths: []string{"/" + service.Name},
+				Hosts: []string{EdgeXService},
+			},
+			auth: authentication(config, service.Name),
+		})
+	}
+
+	admin, err := kongServiceFromConfig(AdminService, config.KongURL.Server, config.KongURL.AdminPort, "http")
+	if err != nil {
+		return nil, err
+	}
+	desired = append(desired, desiredService{
+		service: admin,
+		route:   &kong.Route{Paths: []string{"/" + AdminService}},
+		auth:    authentication(config, AdminService),
+	})
+	return desired, nil
+}
+
+func diffKongState(config *tomlConfig, state kongState, cert string, key string) ([]syncChange, error) {
+	changes := []syncChange{}
+	desired, err := desiredServices(config)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := map[string]kong.Service{}
+	for _, s := range state.services {
+		existing[s.Name] = s
+	}
+	wanted := map[string]bool{}
+
+	for _, d := range desired {
+		wanted[d.service.Name] = true
+		service := d.service
+
+		current, found := existing[service.Name]
+		if !found {
+			changes = append(changes, syncChange{
+				Action: "create",
+				Object: "service",
+				Name:   service.Name,
+				apply: func(kc *kong.Client) error {
+					_, err := kc.CreateService(service)
+					return err
+				},
+			})
+		} else {
+			fields := &kong.Service{}
+			changed := false
+			if current.Host != service.Host {
+				fields.Host = service.Host
+				changed = true
+			}
+			if current.Port != service.Port {
+				fields.Port = service.Port
+				changed = true
+			}
+			if current.Protocol != service.Protocol {
+				fields.Protocol = service.Protocol
+				changed = true
+			}
+			if changed {
+				id := current.ID
+				changes = append(changes, syncChange{
+					Action: "update",
+					Object: "service",
+					Name:   service.Name,
+					apply: func(kc *kong.Client) error {
+						_, err := kc.UpdateService(id, fields)
+						return err
+					},
+				})
+			}
+		}
+
+		changes = append(changes, diffRoutes(d, current, state)...)
+		changes = append(changes, diffPlugins(config, d, current, state)...)
+	}
+
+	for _, s := range state.services {
+		if wanted[s.Name] || !isOwnedService(s, state) {
+			continue
+		}
+		for _, p := range state.plugins {
+			if p.ServiceID == s.ID {
+				id := p.ID
+				changes = append(changes, deleteChange("plugin", fmt.Sprintf("%s/%s", s.Name, p.Name), func(kc *kong.Client) error {
+					return kc.DeletePlugin(id)
+				}))
+			}
+		}
+		for _, r := range state.routes {
+			if routeServiceID(r) == s.ID {
+				id := r.ID
+				changes = append(changes, deleteChange("route", s.Name, func(kc *kong.Client) error {
+					return kc.DeleteRoute(id)
+				}))
+			}
+		}
+		id := s.ID
+		changes = append(changes, deleteChange("service", s.Name, func(kc *kong.Client) error {
+			return kc.DeleteService(id)
+		}))
+	}
+
+	if cert != "" {
+		changes = append(changes, diffCertificate(config, state, cert, key)...)
+	}
+	return changes, nil
+}
+
+func diffRoutes(d desiredService, current kong.Service, state kongState) []syncChange {
+	changes := []syncChange{}
+	owned := []kong.Route{}
+	if current.ID != "" {
+		for _, r := range state.routes {
+			if routeServiceID(r) == current.ID && isOwnedRoute(r, d.service.Name) {
+				owned = append(owned, r)
+			}
+		}
+	}
+
+	name := d.service.Name
+	if len(owned) == 0 {
+		return append(changes, syncChange{
+			Action: "create",
+			Object: "route",
+			Name:   name,
+			apply: func(kc *kong.Client) error {
+				_, err := kc.CreateRoute(name, d.route)
+				return err
+			},
+		})
+	}
+
+	first := owned[0]
+	fields := &kong.Route{}
+	changed := false
+	if !equalStrings(first.Paths, d.route.Paths) {
+		fields.Paths = d.route.Paths
+		changed = true
+	}
+	if !equalStrings(first.Hosts, d.route.Hosts) {
+		fields.Hosts = d.route.Hosts
+		changed = true
+	}
+	if changed {
+		changes = append(changes, syncChange{
+			Action: "update",
+			Object: "route",
+			Name:   name,
+			apply: func(kc *kong.Client) error {
+				_, err := kc.UpdateRoute(first.ID, fields)
+				return err
+			},
+		})
+	}
+	for _, r := range owned[1:] {
+		id := r.ID
+		changes = append(changes, deleteChange("route", name, func(kc *kong.Client) error {
+			return kc.DeleteRoute(id)
+		}))
+	}
+	return changes
+}
+
+func diffPlugins(config *tomlConfig, d desiredService, current kong.Service, state kongState) []syncChange {
+	name := d.service.Name
+	existing := map[string]kong.Plugin{}
+	if current.ID != "" {
+		for _, p := range state.plugins {
+			if p.ServiceID == current.ID {
+				existing[p.Name] = p
+			}
+		}
+	}
+
+	// the authentication plugins other than the one configured are removed, e.g. after
+	// switching a service from jwt to key-auth
+	changes := []syncChange{}
+	for _, plugin := range authPlugins {
+		var desired *kong.Plugin
+		if plugin == d.auth || (plugin == LDAPAuthPlugin && adminDirectory(config, name)) {
+			desired = authPlugin(config, name, plugin)
+		}
+		changes = append(changes, diffPlugin(name, plugin, desired, existing)...)
+	}
+	return append(changes, diffPlugin(name, ACLPlugin, aclPlugin(config, name), existing)...)
+}
+
+// diffPlugin compares the config fields this tool manages on the plugin. A nil desired plugin
+// is removed from the service.
+func diffPlugin(service string, plugin string, desired *kong.Plugin, existing map[string]kong.Plugin) []syncChange {
+	label := fmt.Sprintf("%s/%s", service, plugin)
+	current, found := existing[plugin]
+	switch {
+	case desired == nil && !found:
+		return nil
+	case desired == nil:
+		id := current.ID
+		return []syncChange{deleteChange("plugin", label, func(kc *kong.Client) error {
+			return kc.DeletePlugin(id)
+		})}
+	case !found:
+		return []syncChange{{
+			Action: "create",
+			Object: "plugin",
+			Name:   label,
+			apply: func(kc *kong.Client) error {
+				_, err := kc.CreateServicePlugin(service, desired)
+				return err
+			},
+		}}
+	}
+
+	update := map[string]interface{}{}
+	for _, field := range managedPluginFields[plugin] {
+		if configValue(current.Config, field) == configValue(desired.Config, field) {
+			continue
+		}
+		value := desired.Config[field]
+		if value == nil {
+			// the managed fields left out of a desired config are lists
+			value = []string{}
+		}
+		update[field] = value
+	}
+	if len(update) == 0 {
+		return nil
+	}
+	if added := addedClaimsToVerify(current.Config, desired.Config); plugin == JWTPlugin && len(added) > 0 {
+		// tokens issued before may lack the claims, e.g. exp
+		lc.Warn(fmt.Sprintf("The jwt plugin of service %s verifies %s from now on, tokens issued without these claims are refused and need to be issued again.", service, strings.Join(added, ",")))
+	}
+	id := current.ID
+	return []syncChange{{
+		Action: "update",
+		Object: "plugin",
+		Name:   label,
+		apply: func(kc *kong.Client) error {
+			_, err := kc.UpdatePlugin(id, &kong.Plugin{Config: update})
+			return err
+		},
+	}}
+}
+
+// addedClaimsToVerify returns the claims_to_verify of desired the current plugin does not verify
+func addedClaimsToVerify(current map[string]interface{}, desired map[string]interface{}) []string {
+	before := strings.Split(configValue(current, "claims_to_verify"), ",")
+	added := []string{}
+	for _, claim := range strings.Split(configValue(desired, "claims_to_verify"), ",") {
+		if claim != "" && !containsString(before, claim) {
+			added = append(added, claim)
+		}
+	}
+	return added
+}
+
+// configValue renders a plugin config field for comparison. Kong returns an empty list as {},
+// so an empty object reads the same as an empty or missing list.
+func configValue(config map[string]interface{}, key string) string {
+	switch v := config[key].(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, ",")
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return strings.Join(values, ",")
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return ""
+		}
+	}
+	return fmt.Sprint(config[key])
+}
+
+func diffCertificate(config *tomlConfig, state kongState, cert string, key string) []syncChange {
+	sni := config.SecretService.SNIS
+	for _, c := range state.certs {
+		if !containsString(c.Snis, sni) {
+			continue
+		}
+		if c.Cert == cert && c.Key == key {
+			return nil
+		}
+		id := c.ID
+		return []syncChange{{
+			Action: "update",
+			Object: "certificate",
+			Name:   sni,
+			apply: func(kc *kong.Client) error {
+				_, err := kc.UpdateCertificate(id, &kong.Certificate{Cert: cert, Key: key})
+				return err
+			},
+		}}
+	}
+	return []syncChange{{
+		Action: "create",
+		Object: "certificate",
+		Name:   sni,
+		apply: func(kc *kong.Client) error {
+			_, err := kc.CreateCertificate(&kong.Certificate{Cert: cert, Key: key, Snis: []string{sni}})
+			return err
+		},
+	}}
+}
+
+// a service belongs to this tool when it is the admin loopback or carries an edgex route
+func isOwnedService(s kong.Service, state kongState) bool {
+	if s.Name == AdminService {
+		return true
+	}
+	for _, r := range state.routes {
+		if routeServiceID(r) == s.ID && containsString(r.Hosts, EdgeXService) {
+			return true
+		}
+	}
+	return false
+}
+
+func isOwnedRoute(r kong.Route, service string) bool {
+	if service == AdminService {
+		return containsString(r.Paths, "/"+AdminService)
+	}
+	return containsString(r.Hosts, EdgeXService)
+}
+
+func routeServiceID(r kong.Route) string {
+	if r.Service == nil {
+		return ""
+	}
+	return r.Service.ID
+}
+
+func deleteChange(object string, name string, apply func(kc *kong.Client) error) syncChange {
+	return syncChange{Action: "delete", Object: object, Name: name, apply: apply}
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,293 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+func syncTestConfig() *tomlConfig {
+	return &tomlConfig{
+		KongURL:       kongurl{Server: "kong", AdminPort: "8001"},
+		SecretService: secretservice{SNIS: "edgex.com"},
+		Roles:         map[string]role{"admin": {Services: []string{"*"}}},
+		EdgexServices: map[string]service{
+			"coredata": {Name: "coredata", Host: "edgex-core-data", Port: "48080", Protocol: "http"},
+		},
+		LDAP: ldapconfig{Host: "ldap", Port: "389", BaseDN: "dc=edgexfoundry,dc=org", Attribute: "uid"},
+	}
+}
+
+// syncedState is the reverse proxy as sync leaves it for the configuration
+func syncedState(t *testing.T, config *tomlConfig) kongState {
+	desired, err := desiredServices(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := kongState{certs: []kong.Certificate{{ID: "cert", Cert: "cert", Key: "key", Snis: []string{"edgex.com"}}}}
+	for _, d := range desired {
+		s := *d.service
+		s.ID = "service-" + s.Name
+		state.services = append(state.services, s)
+		r := *d.route
+		r.ID = "route-" + s.Name
+		r.Service = &kong.ServiceRef{ID: s.ID}
+		state.routes = append(state.routes, r)
+
+		plugins := []*kong.Plugin{authPlugin(config, s.Name, d.auth), aclPlugin(config, s.Name)}
+		if adminDirectory(config, s.Name) {
+			plugins = append(plugins, authPlugin(config, s.Name, LDAPAuthPlugin))
+		}
+		for _, p := range plugins {
+			if p == nil {
+				continue
+			}
+			p.ID = fmt.Sprintf("plugin-%s-%s", s.Name, p.Name)
+			p.ServiceID = s.ID
+			state.plugins = append(state.plugins, *p)
+		}
+	}
+	return state
+}
+
+func withoutService(state *kongState, name string) {
+	id := "service-" + name
+	services := []kong.Service{}
+	for _, s := range state.services {
+		if s.ID != id {
+			services = append(services, s)
+		}
+	}
+	routes := []kong.Route{}
+	for _, r := range state.routes {
+		if routeServiceID(r) != id {
+			routes = append(routes, r)
+		}
+	}
+	plugins := []kong.Plugin{}
+	for _, p := range state.plugins {
+		if p.ServiceID != id {
+			plugins = append(plugins, p)
+		}
+	}
+	state.services, state.routes, state.plugins = services, routes, plugins
+}
+
+func pluginOf(state *kongState, service string, name string) *kong.Plugin {
+	for i := range state.plugins {
+		if state.plugins[i].ID == fmt.Sprintf("plugin-%s-%s", service, name) {
+			return &state.plugins[i]
+		}
+	}
+	return nil
+}
+
+func TestDiffKongState(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(config *tomlConfig, state *kongState)
+		want   []string
+	}{
+		{
+			name:   "in sync",
+			change: func(config *tomlConfig, state *kongState) {},
+		},
+		{
+			name: "missing service",
+			change: func(config *tomlConfig, state *kongState) {
+				withoutService(state, "coredata")
+			},
+			want: []string{"create service coredata", "create route coredata", "create plugin coredata/jwt", "create plugin coredata/acl"},
+		},
+		{
+			name: "changed host and port",
+			change: func(config *tomlConfig, state *kongState) {
+				config.EdgexServices["coredata"] = service{Name: "coredata", Host: "core-data", Port: "48081", Protocol: "http"}
+			},
+			want: []string{"update service coredata"},
+		},
+		{
+			name: "changed route",
+			change: func(config *tomlConfig, state *kongState) {
+				state.routes[0].Paths = []string{"/core"}
+			},
+			want: []string{"update route coredata"},
+		},
+		{
+			name: "duplicate route",
+			change: func(config *tomlConfig, state *kongState) {
+				state.routes = append(state.routes, kong.Route{ID: "dup", Paths: []string{"/coredata"}, Hosts: []string{EdgeXService}, Service: &kong.ServiceRef{ID: "service-coredata"}})
+			},
+			want: []string{"delete route coredata"},
+		},
+		{
+			name: "removed from the configuration",
+			change: func(config *tomlConfig, state *kongState) {
+				delete(config.EdgexServices, "coredata")
+			},
+			want: []string{"delete plugin coredata/jwt", "delete plugin coredata/acl", "delete route coredata", "delete service coredata"},
+		},
+		{
+			name: "foreign service",
+			change: func(config *tomlConfig, state *kongState) {
+				state.services = append(state.services, kong.Service{ID: "foreign", Name: "foreign", Host: "x", Port: 80, Protocol: "http"})
+				state.routes = append(state.routes, kong.Route{ID: "foreign-route", Paths: []string{"/foreign"}, Hosts: []string{"other"}, Service: &kong.ServiceRef{ID: "foreign"}})
+				state.plugins = append(state.plugins, kong.Plugin{ID: "foreign-plugin", Name: "key-auth", ServiceID: "foreign"})
+			},
+		},
+		{
+			name: "foreign route and plugin on an owned service",
+			change: func(config *tomlConfig, state *kongState) {
+				state.routes = append(state.routes, kong.Route{ID: "foreign-route", Paths: []string{"/other"}, Hosts: []string{"other"}, Service: &kong.ServiceRef{ID: "service-coredata"}})
+				state.plugins = append(state.plugins, kong.Plugin{ID: "rate", Name: "rate-limiting", ServiceID: "service-coredata"})
+			},
+		},
+		{
+			name: "switched authentication",
+			change: func(config *tomlConfig, state *kongState) {
+				config.EdgexServices["coredata"] = service{Name: "coredata", Host: "edgex-core-data", Port: "48080", Protocol: "http", Authentication: KeyAuthPlugin}
+			},
+			want: []string{"delete plugin coredata/jwt", "create plugin coredata/key-auth"},
+		},
+		{
+			name: "public service",
+			change: func(config *tomlConfig, state *kongState) {
+				config.EdgexServices["coredata"] = service{Name: "coredata", Host: "edgex-core-data", Port: "48080", Protocol: "http", Authentication: NoAuth}
+			},
+			want: []string{"delete plugin coredata/jwt", "delete plugin coredata/acl"},
+		},
+		{
+			name: "changed acl whitelist",
+			change: func(config *tomlConfig, state *kongState) {
+				pluginOf(state, "coredata", ACLPlugin).Config = map[string]interface{}{"whitelist": []interface{}{"operator"}}
+			},
+			want: []string{"update plugin coredata/acl"},
+		},
+		{
+			name: "unmanaged plugin field",
+			change: func(config *tomlConfig, state *kongState) {
+				pluginOf(state, "coredata", JWTPlugin).Config = map[string]interface{}{"uri_param_names": []interface{}{"jwt"}}
+			},
+		},
+		{
+			name: "empty list returned as an object",
+			change: func(config *tomlConfig, state *kongState) {
+				pluginOf(state, "coredata", JWTPlugin).Config = map[string]interface{}{"claims_to_verify": map[string]interface{}{}}
+			},
+		},
+		{
+			name: "claims added",
+			change: func(config *tomlConfig, state *kongState) {
+				config.JWT.ClaimsToVerify = []string{"exp"}
+			},
+			want: []string{"update plugin coredata/jwt", "update plugin admin/jwt"},
+		},
+		{
+			name: "directory on the admin loopback",
+			change: func(config *tomlConfig, state *kongState) {
+				config.LDAP.Admin = true
+			},
+			want: []string{"create plugin admin/ldap-auth"},
+		},
+		{
+			name: "changed certificate",
+			change: func(config *tomlConfig, state *kongState) {
+				state.certs[0].Cert = "old"
+			},
+			want: []string{"update certificate edgex.com"},
+		},
+		{
+			name: "missing certificate",
+			change: func(config *tomlConfig, state *kongState) {
+				state.certs = nil
+			},
+			want: []string{"create certificate edgex.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		config := syncTestConfig()
+		state := syncedState(t, config)
+		tt.change(config, &state)
+		changes, err := diffKongState(config, state, "cert", "key")
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		got := []string{}
+		for _, c := range changes {
+			got = append(got, fmt.Sprintf("%s %s %s", c.Action, c.Object, c.Name))
+		}
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffKongStateInvalidAuthentication(t *testing.T) {
+	config := syncTestConfig()
+	config.EdgexServices["coredata"] = service{Name: "coredata", Host: "edgex-core-data", Port: "48080", Protocol: "http", Authentication: "saml"}
+	if _, err := diffKongState(config, kongState{}, "", ""); err == nil {
+		t.Error("expected an error for an unsupported authentication")
+	}
+}
+
+func TestConfigValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"a", "b"}, "a,b"},
+		{[]interface{}{"a", "b"}, "a,b"},
+		{map[string]interface{}{}, ""},
+		{true, "true"},
+		{float64(3600), "3600"},
+	}
+	for _, tt := range tests {
+		if got := configValue(map[string]interface{}{"field": tt.value}, "field"); got != tt.want {
+			t.Errorf("configValue(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAddedClaimsToVerify(t *testing.T) {
+	tests := []struct {
+		current interface{}
+		desired interface{}
+		want    []string
+	}{
+		{nil, []string{"exp"}, []string{"exp"}},
+		{map[string]interface{}{}, []string{"exp", "nbf"}, []string{"exp", "nbf"}},
+		{[]interface{}{"exp"}, []string{"exp", "nbf"}, []string{"nbf"}},
+		{[]interface{}{"exp"}, []string{"exp"}, []string{}},
+		{[]interface{}{"exp", "nbf"}, nil, []string{}},
+	}
+	for _, tt := range tests {
+		got := addedClaimsToVerify(map[string]interface{}{"claims_to_verify": tt.current}, map[string]interface{}{"claims_to_verify": tt.desired})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("addedClaimsToVerify(%v, %v) = %v, want %v", tt.current, tt.desired, got, tt.want)
+		}
+	}
+}
@@ -19,7 +19,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/sling"
 )
 
 type Secret struct {
@@ -35,3 +41,84 @@ func getSecret(filename string) (Secret, error) {
 	err = json.Unmarshal(raw, &s)
 	return s, err
 }
+
+// vaultKV reads and writes secrets of the kv engine with the root token of the secret service
+type vaultKV struct {
+	client  *http.Client
+	baseURL string
+	token   string
+}
+
+type vaultKVSecret struct {
+	Data json.RawMessage `json:"data"`
+}
+
+func newVaultKV(config *tomlConfig, secretBaseURL string, c *http.Client) (*vaultKV, error) {
+	t, err := getSecret(config.SecretService.TokenPath)
+	if err != nil {
+		return nil, err
+	}
+	return &vaultKV{client: c, baseURL: secretBaseURL, token: t.Token}, nil
+}
+
+func (v *vaultKV) request() *sling.Sling {
+	return sling.New().Base(v.baseURL).Set(VaultToken, v.token)
+}
+
+// read decodes the data of the secret into out and returns false when there is no such secret
+func (v *vaultKV) read(path string, out interface{}) (bool, error) {
+	secret := vaultKVSecret{}
+	code, err := doVaultRequest(v.client, v.request().Get(path), &secret)
+	if err == nil && code == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil || code != 200 {
+		return false, vaultKVError("read", path, code, err)
+	}
+	if len(secret.Data) == 0 {
+		return true, nil
+	}
+	if err := json.Unmarshal(secret.Data, out); err != nil {
+		return false, vaultKVError("read", path, code, err)
+	}
+	return true, nil
+}
+
+// write replaces the secret as a whole
+func (v *vaultKV) write(path string, data interface{}) error {
+	code, err := doVaultRequest(v.client, v.request().Post(path).BodyJSON(data), nil)
+	if err != nil || code/100 != 2 {
+		return vaultKVError("write", path, code, err)
+	}
+	return nil
+}
+
+func (v *vaultKV) remove(path string) error {
+	code, err := doVaultRequest(v.client, v.request().Delete(path), nil)
+	if err != nil || (code/100 != 2 && code != http.StatusNotFound) {
+		return vaultKVError("delete", path, code, err)
+	}
+	return nil
+}
+
+func vaultKVError(action string, path string, code int, err error) error {
+	s := fmt.Sprintf("Failed to %s secret %s in the secret service with errorcode %d.", action, path, code)
+	if err != nil {
+		s = fmt.Sprintf("Failed to %s secret %s in the secret service with error %s.", action, path, err.Error())
+	}
+	lc.Error(s)
+	return errors.New(s)
+}
+
+// kvPath joins the configured kv path and the username, which must stay a single segment of the
+// path
+func kvPath(path string, user string) (string, error) {
+	if user == "" || user == "." || user == ".." || strings.ContainsAny(user, "/\\?#%") {
+		s := fmt.Sprintf("User name %s can't be used as a path in the secret service.", user)
+		return "", errors.New(s)
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return path + user, nil
+}
@@ -25,6 +25,17 @@ type tomlConfig struct {
 	KongURL       kongurl
 	KongAdmin     kongadmin
 	SecretService secretservice
+	Retry         retry
+	Consul        consul
+	Server        server
+	Rotation      rotation
+	Revocation    revocation
+	Roles         map[string]role
+	JWT           jwtconfig
+	OAuth2        oauth2config
+	JWKS          jwksconfig
+	LDAP          ldapconfig
+	Usernames     usernames
 	EdgexServices map[string]service
 }
 
@@ -32,6 +43,8 @@ type kongurl struct {
 	Server          string
 	AdminPort       string
 	ApplicationPort string
+	PublicURL       string
+	PageSize        int
 }
 
 type kongadmin struct {
@@ -40,19 +53,115 @@ type kongadmin struct {
 }
 
 type secretservice struct {
-	Server          string
+	Protocol         string
+	Server           string
+	Port             string
+	HealthcheckPath  string
+	CertPath         string
+	TokenPath        string
+	SNIS             string
+	StandbyOK        bool
+	TransitPath      string
+	TransitKeyPrefix string
+	APIKeyPath       string
+	StoreAPIKeys     bool
+	CredentialPath   string
+	StoreCredentials bool
+}
+
+type jwtconfig struct {
+	Issuer         string
+	Algorithm      string
+	Signer         string
+	TTL            string
+	Audience       string
+	ClaimsToVerify []string
+	Claims         map[string]string
+}
+
+type oauth2config struct {
+	TokenExpiration   int
+	GlobalCredentials bool
+	RedirectURI       string
+}
+
+type jwksconfig struct {
+	RefreshInterval string
+	CAFile          string
+	Issuers         map[string]issuer
+}
+
+type issuer struct {
+	Iss      string
+	JWKSURL  string
+	JWKSFile string
+	Kid      string
+	Roles    []string
+}
+
+type ldapconfig struct {
+	Host            string
 	Port            string
-	HealthcheckPath string
-	CertPath        string
-	TokenPath       string
-	SNIS            string
+	BaseDN          string
+	Attribute       string
+	StartTLS        bool
+	VerifyLDAPHost  bool
+	Timeout         int
+	CacheTTL        int
+	HideCredentials bool
+	Admin           bool
+}
+
+type usernames struct {
+	Pattern   string
+	MinLength int
+	MaxLength int
+	Reserved  []string
+}
+
+type rotation struct {
+	Grace        string
+	Ledger       string
+	ReapInterval string
+}
+
+type revocation struct {
+	List string
+}
+
+type role struct {
+	Services []string
+}
+
+type server struct {
+	Host string
+	Port string
+}
+
+type consul struct {
+	Server string
+	Port   string
+	Prefix string
+	Token  string
+	Seed   bool
+}
+
+type retry struct {
+	Timeout         string
+	InitialInterval string
+	MaxInterval     string
+	Multiplier      float64
+	Jitter          float64
+	Attempts        int
 }
 
 type service struct {
-	Name     string
-	Host     string
-	Port     string
-	Protocol string
+	Name           string
+	Host           string
+	Port           string
+	Protocol       string
+	Authentication string
+	KeyNames       []string
 }
 
 func LoadTomlConfig(path string) (*tomlConfig, error) {
@@ -0,0 +1,249 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/sling"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// key types of the vault transit engine for the asymmetric jwt algorithms
+var transitKeyTypes = map[string]string{
+	"RS256": "rsa-2048",
+	"ES256": "ecdsa-p256",
+}
+
+type transitKeyRequest struct {
+	Type string `json:"type"`
+}
+
+type transitKey struct {
+	Data struct {
+		Type          string `json:"type"`
+		LatestVersion int    `json:"latest_version"`
+		Keys          map[string]struct {
+			PublicKey string `json:"public_key"`
+		} `json:"keys"`
+	} `json:"data"`
+}
+
+type transitSignRequest struct {
+	Input               string `json:"input"`
+	SignatureAlgorithm  string `json:"signature_algorithm,omitempty"`
+	MarshalingAlgorithm string `json:"marshaling_algorithm,omitempty"`
+}
+
+type transitSignature struct {
+	Data struct {
+		Signature string `json:"signature"`
+	} `json:"data"`
+}
+
+// transitSigner keeps the signing keys of consumers in the vault transit engine, one key per
+// consumer, so the private keys never leave the secret service.
+type transitSigner struct {
+	client  *http.Client
+	baseURL string
+	path    string
+	prefix  string
+	token   string
+	dryRun  bool
+}
+
+// newTransitSigner returns nil when [jwt] signer asks for keys generated by the tool itself
+func newTransitSigner(config *tomlConfig, secretBaseURL string, c *http.Client, dryRun bool) (*transitSigner, error) {
+	switch config.JWT.Signer {
+	case "", "local":
+		return nil, nil
+	case "vault":
+	default:
+		s := fmt.Sprintf("Unsupported jwt signer %s, expected local or vault.", config.JWT.Signer)
+		return nil, errors.New(s)
+	}
+
+	t, err := getSecret(config.SecretService.TokenPath)
+	if err != nil {
+		return nil, err
+	}
+	path := config.SecretService.TransitPath
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return &transitSigner{
+		client:  c,
+		baseURL: secretBaseURL,
+		path:    path,
+		prefix:  config.SecretService.TransitKeyPrefix,
+		token:   t.Token,
+		dryRun:  dryRun,
+	}, nil
+}
+
+func (t *transitSigner) keyName(user string) string {
+	return t.prefix + user
+}
+
+func (t *transitSigner) do(s *sling.Sling, out interface{}) (int, error) {
+	return doVaultRequest(t.client, s, out)
+}
+
+// doVaultRequest returns the status code of the response and decodes a successful one into out
+func doVaultRequest(c *http.Client, s *sling.Sling, out interface{}) (int, error) {
+	req, err := s.Request()
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, nil
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func (t *transitSigner) request() *sling.Sling {
+	return sling.New().Base(t.baseURL).Set(VaultToken, t.token)
+}
+
+// publicKey creates the transit key of the user when it does not exist yet and returns the
+// PEM encoded public key of its latest version
+func (t *transitSigner) publicKey(user string, alg string) (string, error) {
+	name := t.keyName(user)
+	keyType, ok := transitKeyTypes[alg]
+	if !ok {
+		s := fmt.Sprintf("Jwt algorithm %s can't be signed with the secret service, expected RS256 or ES256.", alg)
+		return "", errors.New(s)
+	}
+
+	key := transitKey{}
+	code, err := t.do(t.request().Get(t.path+"keys/"+name), &key)
+	if err == nil && code == http.StatusNotFound {
+		code, err = t.do(t.request().Post(t.path+"keys/"+name).BodyJSON(&transitKeyRequest{Type: keyType}), nil)
+		if err != nil || code/100 != 2 {
+			return "", transitError("create transit key", name, code, err)
+		}
+		lc.Info(fmt.Sprintf("Successful to create transit key %s.", name))
+		if t.dryRun {
+			// the key is only planned, there is no public key to register yet
+			return "", nil
+		}
+		code, err = t.do(t.request().Get(t.path+"keys/"+name), &key)
+	}
+	if err != nil || code != 200 {
+		return "", transitError("read transit key", name, code, err)
+	}
+
+	if key.Data.Type != keyType {
+		s := fmt.Sprintf("Transit key %s is of type %s, %s needs %s.", name, key.Data.Type, alg, keyType)
+		lc.Error(s)
+		return "", errors.New(s)
+	}
+	latest := key.Data.Keys[fmt.Sprint(key.Data.LatestVersion)]
+	lc.Info(fmt.Sprintf("Successful to read the public key of transit key %s version %d.", name, key.Data.LatestVersion))
+	return latest.PublicKey, nil
+}
+
+// sign has vault sign the header and claims of the token and returns the complete token
+func (t *transitSigner) sign(user string, alg string, token *jwt.Token) (string, error) {
+	name := t.keyName(user)
+	signing, err := token.SigningString()
+	if err != nil {
+		return "", err
+	}
+
+	if t.dryRun {
+		// signing changes nothing, the planned token is left unsigned
+		return signing + ".", nil
+	}
+
+	body := &transitSignRequest{Input: base64.StdEncoding.EncodeToString([]byte(signing))}
+	switch alg {
+	case "RS256":
+		body.SignatureAlgorithm = "pkcs1v15"
+	case "ES256":
+		// jws marshaling gives the raw r||s form a jwt expects, already base64url encoded
+		body.MarshalingAlgorithm = "jws"
+	}
+
+	result := transitSignature{}
+	code, err := t.do(t.request().Post(t.path+"sign/"+name+"/sha2-256").BodyJSON(body), &result)
+	if err != nil || code != 200 {
+		return "", transitError("sign token with transit key", name, code, err)
+	}
+
+	// signatures look like vault:v1:<base64>
+	parts := strings.SplitN(result.Data.Signature, ":", 3)
+	if len(parts) != 3 {
+		s := fmt.Sprintf("Unexpected signature format from transit key %s.", name)
+		lc.Error(s)
+		return "", errors.New(s)
+	}
+	signature := parts[2]
+	if alg == "RS256" {
+		raw, err := base64.StdEncoding.DecodeString(signature)
+		if err != nil {
+			return "", err
+		}
+		signature = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return signing + "." + signature, nil
+}
+
+func transitError(action string, name string, code int, err error) error {
+	s := fmt.Sprintf("Failed to %s %s with errorcode %d.", action, name, code)
+	if err != nil {
+		s = fmt.Sprintf("Failed to %s %s with error %s.", action, name, err.Error())
+	}
+	lc.Error(s)
+	return errors.New(s)
+}
+
+// rotateKey adds a new version to the transit key of the user, tokens are signed with the
+// latest version from then on
+func (t *transitSigner) rotateKey(user string) error {
+	name := t.keyName(user)
+	code, err := t.do(t.request().Get(t.path+"keys/"+name), nil)
+	if err == nil && code == http.StatusNotFound {
+		// nothing to rotate, publicKey creates the key
+		return nil
+	}
+	if err != nil || code != 200 {
+		return transitError("read transit key", name, code, err)
+	}
+
+	code, err = t.do(t.request().Post(t.path+"keys/"+name+"/rotate"), nil)
+	if err != nil || code/100 != 2 {
+		return transitError("rotate transit key", name, code, err)
+	}
+	lc.Info(fmt.Sprintf("Successful to rotate transit key %s.", name))
+	return nil
+}
@@ -0,0 +1,253 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// transitStandIn keeps versioned keys the way the vault transit engine does and signs with the
+// latest version
+type transitStandIn struct {
+	*httptest.Server
+	mutex     sync.Mutex
+	keys      map[string][]crypto.Signer
+	types     map[string]string
+	malformed bool
+}
+
+func startTransit(t *testing.T) *transitStandIn {
+	v := &transitStandIn{keys: map[string][]crypto.Signer{}, types: map[string]string{}}
+	v.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v.mutex.Lock()
+		defer v.mutex.Unlock()
+		if r.Header.Get(VaultToken) != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/transit/"), "/")
+		switch {
+		case parts[0] == "keys" && len(parts) == 2 && r.Method == "GET":
+			v.readKey(w, parts[1])
+		case parts[0] == "keys" && len(parts) == 2 && r.Method == "POST":
+			body := transitKeyRequest{}
+			json.NewDecoder(r.Body).Decode(&body)
+			v.types[parts[1]] = body.Type
+			v.addVersion(t, parts[1])
+			w.WriteHeader(http.StatusNoContent)
+		case parts[0] == "keys" && len(parts) == 3 && parts[2] == "rotate" && r.Method == "POST":
+			v.addVersion(t, parts[1])
+			w.WriteHeader(http.StatusNoContent)
+		case parts[0] == "sign" && len(parts) == 3 && parts[2] == "sha2-256" && r.Method == "POST":
+			body := transitSignRequest{}
+			json.NewDecoder(r.Body).Decode(&body)
+			v.sign(t, w, parts[1], body)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return v
+}
+
+func (v *transitStandIn) addVersion(t *testing.T, name string) {
+	var key crypto.Signer
+	var err error
+	switch v.types[name] {
+	case "rsa-2048":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ecdsa-p256":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		t.Fatalf("unsupported key type %s", v.types[name])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.keys[name] = append(v.keys[name], key)
+}
+
+func (v *transitStandIn) readKey(w http.ResponseWriter, name string) {
+	versions, ok := v.keys[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := transitKey{}
+	key.Data.Type = v.types[name]
+	key.Data.LatestVersion = len(versions)
+	key.Data.Keys = map[string]struct {
+		PublicKey string `json:"public_key"`
+	}{}
+	for i, k := range versions {
+		der, _ := x509.MarshalPKIXPublicKey(k.Public())
+		entry := key.Data.Keys[fmt.Sprint(i+1)]
+		entry.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		key.Data.Keys[fmt.Sprint(i+1)] = entry
+	}
+	json.NewEncoder(w).Encode(key)
+}
+
+func (v *transitStandIn) sign(t *testing.T, w http.ResponseWriter, name string, body transitSignRequest) {
+	versions := v.keys[name]
+	input, err := base64.StdEncoding.DecodeString(body.Input)
+	if err != nil || len(versions) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	digest := sha256.Sum256(input)
+	signature := ""
+	switch key := versions[len(versions)-1].(type) {
+	case *rsa.PrivateKey:
+		if body.SignatureAlgorithm != "pkcs1v15" {
+			t.Errorf("rsa signature algorithm %q, want pkcs1v15", body.SignatureAlgorithm)
+		}
+		raw, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		signature = base64.StdEncoding.EncodeToString(raw)
+	case *ecdsa.PrivateKey:
+		if body.MarshalingAlgorithm != "jws" {
+			t.Errorf("ecdsa marshaling algorithm %q, want jws", body.MarshalingAlgorithm)
+		}
+		r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+		raw := make([]byte, 64)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(raw[32-len(rb):32], rb)
+		copy(raw[64-len(sb):], sb)
+		signature = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	result := transitSignature{}
+	result.Data.Signature = fmt.Sprintf("vault:v%d:%s", len(versions), signature)
+	if v.malformed {
+		result.Data.Signature = signature
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func testSigner(v *transitStandIn) *transitSigner {
+	return &transitSigner{client: &http.Client{}, baseURL: v.URL + "/", path: "v1/transit/", prefix: "edgex-", token: "vault-token"}
+}
+
+// verifyToken checks the signature of a token against a PEM encoded public key
+func verifyToken(token string, alg string, publicPEM string) error {
+	_, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		if alg == "RS256" {
+			return jwt.ParseRSAPublicKeyFromPEM([]byte(publicPEM))
+		}
+		return jwt.ParseECPublicKeyFromPEM([]byte(publicPEM))
+	})
+	return err
+}
+
+func TestTransitSignAndRotate(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256"} {
+		vault := startTransit(t)
+		signer := testSigner(vault)
+
+		first, err := signer.publicKey("guest", alg)
+		if err != nil {
+			t.Fatalf("%s: %s", alg, err.Error())
+		}
+		if _, ok := vault.keys["edgex-guest"]; !ok {
+			t.Fatalf("%s: the transit key edgex-guest was not created", alg)
+		}
+		token, err := signer.sign("guest", alg, jwt.NewWithClaims(jwt.GetSigningMethod(alg), jwt.MapClaims{"iss": "jti-1"}))
+		if err != nil {
+			t.Fatalf("%s: %s", alg, err.Error())
+		}
+		if err := verifyToken(token, alg, first); err != nil {
+			t.Errorf("%s: the signed token does not verify with the public key: %s", alg, err.Error())
+		}
+
+		if err := signer.rotateKey("guest"); err != nil {
+			t.Fatalf("%s: %s", alg, err.Error())
+		}
+		second, err := signer.publicKey("guest", alg)
+		if err != nil {
+			t.Fatalf("%s: %s", alg, err.Error())
+		}
+		if second == first || len(vault.keys["edgex-guest"]) != 2 {
+			t.Fatalf("%s: rotation did not add a key version", alg)
+		}
+		token, err = signer.sign("guest", alg, jwt.NewWithClaims(jwt.GetSigningMethod(alg), jwt.MapClaims{"iss": "jti-2"}))
+		if err != nil {
+			t.Fatalf("%s: %s", alg, err.Error())
+		}
+		if verifyToken(token, alg, second) != nil || verifyToken(token, alg, first) == nil {
+			t.Errorf("%s: a token signed after the rotation must verify with the new key only", alg)
+		}
+		vault.Close()
+	}
+}
+
+func TestTransitRotateMissingKey(t *testing.T) {
+	vault := startTransit(t)
+	defer vault.Close()
+	if err := testSigner(vault).rotateKey("nobody"); err != nil {
+		t.Errorf("rotating a key that does not exist yet = %s, want nothing to do", err.Error())
+	}
+	if len(vault.keys) != 0 {
+		t.Errorf("rotate created keys %v", vault.keys)
+	}
+}
+
+func TestTransitErrors(t *testing.T) {
+	vault := startTransit(t)
+	defer vault.Close()
+	signer := testSigner(vault)
+
+	if _, err := signer.publicKey("guest", "HS256"); err == nil {
+		t.Error("HS256 was accepted for a transit key")
+	}
+	if _, err := signer.publicKey("guest", "RS256"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.publicKey("guest", "ES256"); err == nil {
+		t.Error("the rsa transit key was used for ES256")
+	}
+	vault.malformed = true
+	if _, err := signer.sign("guest", "RS256", jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{})); err == nil {
+		t.Error("a signature without the vault:v<n>: prefix was accepted")
+	}
+	signer.token = "wrong"
+	if _, err := signer.sign("guest", "RS256", jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{})); err == nil {
+		t.Error("a refused sign request was accepted")
+	}
+
+	planned := testSigner(vault)
+	planned.dryRun = true
+	token, err := planned.sign("guest", "RS256", jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{}))
+	if err != nil || !strings.HasSuffix(token, ".") {
+		t.Errorf("a planned token = %q, %v, want it left unsigned", token, err)
+	}
+}
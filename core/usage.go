@@ -24,12 +24,59 @@ import (
 var usageStr = `
 Usage: %s [options]
 Server Options:
-	--consul=true/false				Indicates if retrieving config from Consul
+	--consul=true/false				Indicates if retrieving config from Consul, see [consul] in configuration.toml
 	--insureskipverify=true/false			Indicates if skipping the server side SSL cert verifcation, similar to -k of curl
 	--init=true/false				Indicates if security service should be initialized
 	--reset=true/false				Indicate if security service should be reset to initialization status
-	--useradd=<username>				Create an account and return JWT
+	--sync=true/false				Converge the reverse proxy to the configuration, leaving objects not created by this tool untouched
+	--plan=true/false				Print the requests --init/--reset/--sync/--useradd/--userdel would send to the reverse proxy without sending them
+	--format=text/json/csv				Output format of the plan (text or json) and of the user list (text, json or csv), csv or json
+							override the extension of the --import, --importout and --export files
+	--planout=<file>				Write the plan to a file instead of stdout
+	--useradd=<username>				Create an account and return the JWT and other credentials its services need
+	--customid=<id>					custom_id of the account created with --useradd, e.g. the asset id it belongs to
+	--role=<role>					Role from [roles] the account created with --useradd gets, can be repeated
+	--group=<group>					Extra ACL group the account created with --useradd is put in, can be repeated
+	--basicauth=true/false				Also create a basic-auth credential for the account, services set to basic-auth get one anyway
+	--password=generate/prompt/stdin		Where the basic-auth password comes from, a generated one is printed
+	--algorithm=HS256/RS256/ES256			Algorithm of the JWT credential, RS256/ES256 generate a key pair and register only the public key
+	--keyout=<file>					Write the private key of an RS256/ES256 credential to a file instead of stdout
+	--ttl=<duration>				Lifetime of the JWT returned by --useradd, e.g. 24h, defaults to [jwt] ttl
+	--nbf=<time|duration>				JWT is not valid before this RFC3339 time or duration from now
+	--aud=<audience>				Audience of the JWT, defaults to [jwt] audience
+	--jti=<id>					ID of the JWT, generated when empty
+	--claim=<key=value>				Extra claim added to the JWT, can be repeated
+	--import=<file>					Create the users of a csv or json file with the columns username, custom_id, roles, groups, auth and ttl
+	--importout=<file>				Write the credentials of the imported users to this file, readable by the owner only
+	--workers=<n>					Number of users --import creates at the same time, defaults to 4
+	--export=<file|->				Write the consumers in the reverse proxy to a csv or json file in the import format
+	--rotate=<username>				Add a new JWT credential to an account and return its JWT, the credential given with --credential is retired after the grace period, without --credential all of them and so every JWT of the account
+	--grace=<duration>				How long the old credentials keep working after --rotate, defaults to [rotation] grace
+	--credlist=<username>				List the JWT credentials of an account, every JWT has a credential whose key is its jti
+	--credrevoke=<username>				Revoke the JWT credential of an account given with --credential
+	--credential=<id|key|jti>			ID or key of the JWT credential, api key or OAuth2 application to revoke, or of the JWT credential to rotate
+	--appadd=<username>				Register another OAuth2 application for an account and print its client id and secret
+	--appname=<name>				Name of the application registered with --appadd, defaults to the username
+	--redirecturi=<uri>				Redirect URI of the OAuth2 application, defaults to [oauth2] redirecturi
+	--applist=<username>				List the OAuth2 applications of an account
+	--apprevoke=<username>				Revoke the OAuth2 application of an account given with --credential, by client id or id
+	--keyadd=<username>				Add another generated api key to an account for the key-auth services
+	--keylist=<username>				List the api keys of an account, only the start of each key is shown
+	--keyrevoke=<username>				Revoke the api key of an account given with --credential
+	--tokenget=<username>				Print the current JWT of an account kept in the secret service, --format=json prints all its stored credentials
+	--out=<file>					Write the credentials of --useradd, --rotate, --keyadd, --appadd or --tokenget to a JSON file only the owner can read instead of stdout
+	--recipient=<file>				Encrypt the --out file to the RSA public key in the PEM file, RSA-OAEP-256 with AES-256-GCM
+	--decrypt=<file>				Print the credentials in an encrypted --out file and exit, no reverse proxy is needed
+	--decryptkey=<file>				RSA private key PEM file of the recipient used by --decrypt
+	--revocations=true/false			List the revoked JWT credentials
 	--userdel=<username>				Delete an account		
+	--userlist=true/false				List the accounts with their JWT credentials and groups
+	--filter=<text>					Only list accounts whose name or custom_id contains the text
+	--issuers=true/false				Register the current signing keys of the trusted issuers in [jwks], --init and --sync do so as well
+	--ldapcheck=true/false				Bind to the [ldap] directory as the ldap-auth plugin would and exit, no reverse proxy is needed
+	--ldapuser=<username>				User the --ldapcheck bind is done as, anonymous when empty, --password=prompt/stdin gives the password
+	--serve=true/false				Keep running and serve the account management REST API configured in [server]
+	The service waits for the reverse proxy and the secret service as configured in [retry] and exits with code 1 when they stay down.
 	Common Options:
 	-h, --help					Show this message
 `
@@ -0,0 +1,148 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/edgexfoundry/edgexsecurity/kong"
+)
+
+type userListing struct {
+	Username    string              `json:"username"`
+	ID          string              `json:"id"`
+	CustomID    string              `json:"custom_id,omitempty"`
+	CreatedAt   string              `json:"created_at"`
+	Groups      []string            `json:"groups"`
+	Credentials []credentialListing `json:"jwt_credentials"`
+}
+
+type credentialListing struct {
+	ID        string `json:"id"`
+	Key       string `json:"key"`
+	Algorithm string `json:"algorithm,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// listUsers returns the consumers whose username or custom_id contains filter together with their jwt
+// credentials and acl groups. Secrets are never included.
+func listUsers(kc *kong.Client, filter string) ([]userListing, error) {
+	consumers, err := kc.ListConsumers()
+	if err != nil {
+		return nil, err
+	}
+
+	users := []userListing{}
+	for _, c := range consumers {
+		if filter != "" && !strings.Contains(strings.ToLower(c.Username), strings.ToLower(filter)) && !strings.Contains(strings.ToLower(c.CustomID), strings.ToLower(filter)) {
+			continue
+		}
+		user := userListing{
+			Username:    c.Username,
+			ID:          c.ID,
+			CustomID:    c.CustomID,
+			CreatedAt:   formatKongTime(c.CreatedAt),
+			Groups:      []string{},
+			Credentials: []credentialListing{},
+		}
+
+		creds, err := kc.ListJWTCredentials(c.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, cred := range creds {
+			user.Credentials = append(user.Credentials, credentialListing{
+				ID:        cred.ID,
+				Key:       cred.Key,
+				Algorithm: cred.Algorithm,
+				CreatedAt: formatKongTime(cred.CreatedAt),
+			})
+		}
+
+		acls, err := kc.ListACLs(c.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, acl := range acls {
+			user.Groups = append(user.Groups, acl.Group)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// Kong stores created_at in milliseconds for consumers and credentials, and in seconds for the
+// newer entities
+func formatKongTime(t int64) string {
+	if t == 0 {
+		return ""
+	}
+	if t > 1e12 {
+		return time.Unix(0, t*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+	}
+	return time.Unix(t, 0).UTC().Format(time.RFC3339)
+}
+
+func writeUsers(users []userListing, format string, w io.Writer) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(users)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"username", "id", "custom_id", "created_at", "groups", "credential_id", "credential_key", "credential_algorithm", "credential_created_at"})
+		for _, row := range userRows(users) {
+			cw.Write(row)
+		}
+		cw.Flush()
+		return cw.Error()
+	case "text", "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "USERNAME\tID\tCUSTOM ID\tCREATED\tGROUPS\tCREDENTIAL ID\tKEY\tALGORITHM\tCREDENTIAL CREATED")
+		for _, row := range userRows(users) {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+	s := fmt.Sprintf("Unsupported user list format %s.", format)
+	return errors.New(s)
+}
+
+// one row per credential, users without credentials still get a row
+func userRows(users []userListing) [][]string {
+	rows := [][]string{}
+	for _, u := range users {
+		user := []string{u.Username, u.ID, u.CustomID, u.CreatedAt, strings.Join(u.Groups, ",")}
+		if len(u.Credentials) == 0 {
+			rows = append(rows, append(user, "", "", "", ""))
+			continue
+		}
+		for _, c := range u.Credentials {
+			row := append([]string{}, user...)
+			rows = append(rows, append(row, c.ID, c.Key, c.Algorithm, c.CreatedAt))
+		}
+	}
+	return rows
+}
@@ -0,0 +1,52 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package kong
+
+import "net/url"
+
+// ListCertificates returns the certificates stored in Kong.
+func (k *Client) ListCertificates() ([]Certificate, error) {
+	certs := []Certificate{}
+	err := k.list(certificatesPath, "certificates", &certs)
+	return certs, err
+}
+
+// CreateCertificate uploads a certificate/key pair for the SNIs set on c.
+func (k *Client) CreateCertificate(c *Certificate) (*Certificate, error) {
+	created := &Certificate{}
+	err := k.send("POST", certificatesPath, "certificate "+firstSNI(c), c, created)
+	return created, err
+}
+
+// UpdateCertificate patches the non-empty fields of c onto the certificate with the given id.
+func (k *Client) UpdateCertificate(id string, c *Certificate) (*Certificate, error) {
+	updated := &Certificate{}
+	err := k.send("PATCH", certificatesPath+url.PathEscape(id), "certificate "+id, c, updated)
+	return updated, err
+}
+
+// DeleteCertificate removes the certificate with the given id.
+func (k *Client) DeleteCertificate(id string) error {
+	return k.send("DELETE", certificatesPath+url.PathEscape(id), "certificate "+id, nil, nil)
+}
+
+func firstSNI(c *Certificate) string {
+	if len(c.Snis) > 0 {
+		return c.Snis[0]
+	}
+	return ""
+}
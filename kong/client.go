@@ -0,0 +1,171 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package kong
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/sling"
+)
+
+const (
+	servicesPath     = "services/"
+	routesPath       = "routes/"
+	pluginsPath      = "plugins/"
+	consumersPath    = "consumers/"
+	certificatesPath = "certificates/"
+
+	// DefaultPageSize matches the page size Kong uses when none is requested.
+	DefaultPageSize = 100
+	// MaxPageSize is the largest page Kong serves.
+	MaxPageSize = 1000
+)
+
+// Client talks to the Kong admin API.
+type Client struct {
+	baseURL  string
+	http     *http.Client
+	pageSize int
+}
+
+type list struct {
+	Data   json.RawMessage `json:"data"`
+	Next   string          `json:"next"`
+	Offset string          `json:"offset"`
+}
+
+// NewClient returns a client for the admin API at baseURL, e.g. http://kong:8001/.
+func NewClient(baseURL string, c *http.Client) *Client {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &Client{baseURL: baseURL, http: c, pageSize: DefaultPageSize}
+}
+
+// SetPageSize sets how many objects are requested per page when listing. Kong accepts 1 to 1000,
+// a size of 0 or less selects DefaultPageSize and a larger one MaxPageSize.
+func (k *Client) SetPageSize(size int) {
+	switch {
+	case size <= 0:
+		size = DefaultPageSize
+	case size > MaxPageSize:
+		size = MaxPageSize
+	}
+	k.pageSize = size
+}
+
+// BaseURL returns the admin API address the client was created with.
+func (k *Client) BaseURL() string {
+	return k.baseURL
+}
+
+// Status checks that the admin API answers.
+func (k *Client) Status() error {
+	return k.send("GET", "", "node status", nil, nil)
+}
+
+func (k *Client) send(method string, path string, object string, body interface{}, out interface{}) error {
+	s := sling.New().Base(k.baseURL)
+	switch method {
+	case "GET":
+		s = s.Get(path)
+	case "POST":
+		s = s.Post(path)
+	case "PUT":
+		s = s.Put(path)
+	case "PATCH":
+		s = s.Patch(path)
+	case "DELETE":
+		s = s.Delete(path)
+	}
+	if body != nil {
+		s = s.BodyJSON(body)
+	}
+
+	e := &Error{Method: method, Path: path, Object: object}
+	req, err := s.Request()
+	if err != nil {
+		e.Message = err.Error()
+		return e
+	}
+	resp, err := k.http.Do(req)
+	if err != nil {
+		e.Message = err.Error()
+		return e
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return readError(e, resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		e.StatusCode = resp.StatusCode
+		e.Message = "unable to decode response: " + err.Error()
+		return e
+	}
+	return nil
+}
+
+// list follows Kong's offset cursor until the last page and decodes the data arrays of all
+// pages into out, which must point to a slice.
+func (k *Client) list(path string, object string, out interface{}) error {
+	items := []json.RawMessage{}
+	offset := ""
+	for {
+		query := url.Values{}
+		query.Set("size", strconv.Itoa(k.pageSize))
+		if offset != "" {
+			query.Set("offset", offset)
+		}
+
+		page := list{}
+		if err := k.send("GET", path+"?"+query.Encode(), object, nil, &page); err != nil {
+			return err
+		}
+
+		// an empty collection comes back as "data": {} rather than an empty array
+		data := strings.TrimSpace(string(page.Data))
+		if data != "" && data != "{}" && data != "null" {
+			pageItems := []json.RawMessage{}
+			if err := json.Unmarshal(page.Data, &pageItems); err != nil {
+				return &Error{Method: "GET", Path: path, Object: object, StatusCode: http.StatusOK, Message: "unable to decode response: " + err.Error()}
+			}
+			items = append(items, pageItems...)
+		}
+
+		if page.Offset == "" || page.Offset == offset {
+			break
+		}
+		offset = page.Offset
+	}
+
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return &Error{Method: "GET", Path: path, Object: object, StatusCode: http.StatusOK, Message: "unable to decode response: " + err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package kong
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pagedServices serves total services the way Kong 0.13 pages them, with the offset of the
+// next page until the last one, and records the page sizes that were asked for
+func pagedServices(t *testing.T, total int) (*httptest.Server, *[]string) {
+	sizes := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sizes = append(sizes, r.URL.Query().Get("size"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		start, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		end := start + size
+		if end > total {
+			end = total
+		}
+		page := map[string]interface{}{}
+		data := []Service{}
+		for i := start; i < end; i++ {
+			data = append(data, Service{ID: fmt.Sprintf("id-%d", i), Name: fmt.Sprintf("service-%d", i)})
+		}
+		page["data"] = data
+		if total == 0 {
+			page["data"] = map[string]interface{}{}
+		}
+		if end < total {
+			page["offset"] = strconv.Itoa(end)
+			page["next"] = fmt.Sprintf("/services?offset=%d&size=%d", end, size)
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	return srv, &sizes
+}
+
+func TestListFollowsOffset(t *testing.T) {
+	tests := []struct {
+		total    int
+		pageSize int
+		pages    int
+	}{
+		{0, 100, 1},
+		{1, 100, 1},
+		{100, 100, 1},
+		{250, 100, 3},
+		{250, 1000, 1},
+		{7, 2, 4},
+	}
+	for _, tt := range tests {
+		srv, sizes := pagedServices(t, tt.total)
+		k := NewClient(srv.URL+"/", nil)
+		k.SetPageSize(tt.pageSize)
+		services, err := k.ListServices()
+		srv.Close()
+		if err != nil {
+			t.Errorf("%d services in pages of %d: %s", tt.total, tt.pageSize, err)
+			continue
+		}
+		if len(services) != tt.total || len(*sizes) != tt.pages {
+			t.Errorf("%d services in pages of %d: got %d services in %d pages, want %d pages", tt.total, tt.pageSize, len(services), len(*sizes), tt.pages)
+			continue
+		}
+		for i, s := range services {
+			if s.Name != fmt.Sprintf("service-%d", i) {
+				t.Errorf("%d services in pages of %d: service %d is %s", tt.total, tt.pageSize, i, s.Name)
+				break
+			}
+		}
+	}
+}
+
+func TestListStopsOnRepeatedOffset(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"data": [{"id": "a"}], "offset": "same"}`))
+	}))
+	defer srv.Close()
+	services, err := NewClient(srv.URL+"/", nil).ListServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || len(services) != 2 {
+		t.Errorf("got %d services in %d calls, want the loop to stop after the offset repeats", len(services), calls)
+	}
+}
+
+func TestSetPageSize(t *testing.T) {
+	tests := []struct {
+		size int
+		want string
+	}{
+		{-5, "100"},
+		{0, "100"},
+		{1, "1"},
+		{500, "500"},
+		{1000, "1000"},
+		{1001, "1000"},
+		{50000, "1000"},
+	}
+	for _, tt := range tests {
+		srv, sizes := pagedServices(t, 0)
+		k := NewClient(srv.URL+"/", nil)
+		k.SetPageSize(tt.size)
+		_, err := k.ListServices()
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*sizes) != 1 || (*sizes)[0] != tt.want {
+			t.Errorf("SetPageSize(%d) requested size %v, want %s", tt.size, *sizes, tt.want)
+		}
+	}
+}
+
+// stubKong answers every request with status and body and records what it was sent
+type stubKong struct {
+	*httptest.Server
+	status int
+	body   string
+	method string
+	path   string
+	sent   map[string]interface{}
+}
+
+func startStubKong(status int, body string) *stubKong {
+	k := &stubKong{status: status, body: body}
+	k.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k.method, k.path, k.sent = r.Method, r.URL.EscapedPath(), nil
+		if raw, _ := ioutil.ReadAll(r.Body); len(raw) > 0 {
+			json.Unmarshal(raw, &k.sent)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(k.status)
+		w.Write([]byte(k.body))
+	}))
+	return k
+}
+
+func TestClientRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		reply  string
+		call   func(k *Client) (interface{}, error)
+		method string
+		path   string
+		sent   map[string]interface{}
+		want   interface{}
+	}{
+		{"create service", 201, `{"id": "s-1", "name": "coredata", "host": "edgex-core-data", "port": 48080}`,
+			func(k *Client) (interface{}, error) {
+				return k.CreateService(&Service{Name: "coredata", Host: "edgex-core-data", Port: 48080})
+			},
+			"POST", "/services/", map[string]interface{}{"name": "coredata", "host": "edgex-core-data", "port": 48080.0},
+			&Service{ID: "s-1", Name: "coredata", Host: "edgex-core-data", Port: 48080}},
+		{"get service", 200, `{"id": "s-1", "name": "coredata"}`,
+			func(k *Client) (interface{}, error) { return k.GetService("coredata") },
+			"GET", "/services/coredata", nil, &Service{ID: "s-1", Name: "coredata"}},
+		{"update service", 200, `{"id": "s-1", "name": "coredata", "host": "core-data"}`,
+			func(k *Client) (interface{}, error) { return k.UpdateService("s-1", &Service{Host: "core-data"}) },
+			"PATCH", "/services/s-1", map[string]interface{}{"host": "core-data"}, &Service{ID: "s-1", Name: "coredata", Host: "core-data"}},
+		{"delete service", 204, "",
+			func(k *Client) (interface{}, error) { return nil, k.DeleteService("coredata") },
+			"DELETE", "/services/coredata", nil, nil},
+		{"create route", 201, `{"id": "r-1", "paths": ["/coredata"], "service": {"id": "s-1"}}`,
+			func(k *Client) (interface{}, error) {
+				return k.CreateRoute("s-1", &Route{Paths: []string{"/coredata"}})
+			},
+			"POST", "/services/s-1/routes/", map[string]interface{}{"paths": []interface{}{"/coredata"}},
+			&Route{ID: "r-1", Paths: []string{"/coredata"}, Service: &ServiceRef{ID: "s-1"}}},
+		{"create plugin", 201, `{"id": "p-1", "name": "acl", "config": {"whitelist": ["admin"]}}`,
+			func(k *Client) (interface{}, error) {
+				return k.CreateServicePlugin("coredata", &Plugin{Name: "acl", Config: map[string]interface{}{"whitelist": []string{"admin"}}})
+			},
+			"POST", "/services/coredata/plugins/", map[string]interface{}{"name": "acl", "config": map[string]interface{}{"whitelist": []interface{}{"admin"}}},
+			&Plugin{ID: "p-1", Name: "acl", Config: map[string]interface{}{"whitelist": []interface{}{"admin"}}}},
+		{"create jwt credential of escaped consumer", 201, `{"id": "j-1", "key": "k", "consumer_id": "c-1"}`,
+			func(k *Client) (interface{}, error) { return k.CreateJWTCredential("gw 01", nil) },
+			"POST", "/consumers/gw%2001/jwt/", map[string]interface{}{}, &JWTCredential{ID: "j-1", Key: "k", ConsumerID: "c-1"}},
+		{"delete acl", 204, "",
+			func(k *Client) (interface{}, error) { return nil, k.DeleteACL("guest", "admin") },
+			"DELETE", "/consumers/guest/acls/admin", nil, nil},
+		{"status", 200, `{"database": {"reachable": true}}`,
+			func(k *Client) (interface{}, error) { return nil, k.Status() },
+			"GET", "/", nil, nil},
+	}
+	for _, tt := range tests {
+		stub := startStubKong(tt.status, tt.reply)
+		got, err := tt.call(NewClient(stub.URL+"/", nil))
+		stub.Close()
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err.Error())
+			continue
+		}
+		if stub.method != tt.method || stub.path != tt.path || !reflect.DeepEqual(stub.sent, tt.sent) {
+			t.Errorf("%s: sent %s %s %v, want %s %s %v", tt.name, stub.method, stub.path, stub.sent, tt.method, tt.path, tt.sent)
+		}
+		if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: decoded %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		reply    string
+		call     func(k *Client) error
+		code     int
+		contains string
+	}{
+		{"missing service", 404, `{"message": "Not found"}`,
+			func(k *Client) error { _, err := k.GetService("coredata"); return err },
+			404, "GET services/coredata failed for service coredata with errorcode 404: Not found"},
+		{"existing consumer", 409, `{"username": "already exists with value 'guest'"}`,
+			func(k *Client) error { _, err := k.CreateConsumer(&Consumer{Username: "guest"}); return err },
+			409, "(username already exists with value 'guest')"},
+		{"server error", 500, "An unexpected error occurred",
+			func(k *Client) error { return k.DeleteRoute("r-1") },
+			500, "with errorcode 500: An unexpected error occurred"},
+		{"undecodable reply", 200, "<html>",
+			func(k *Client) error { _, err := k.GetConsumer("guest"); return err },
+			200, "unable to decode response"},
+		{"undecodable list", 200, `{"data": [1, 2]}`,
+			func(k *Client) error { _, err := k.ListConsumers(); return err },
+			200, "unable to decode response"},
+	}
+	for _, tt := range tests {
+		stub := startStubKong(tt.status, tt.reply)
+		err := tt.call(NewClient(stub.URL+"/", nil))
+		stub.Close()
+		if StatusCode(err) != tt.code || err == nil || !strings.Contains(err.Error(), tt.contains) {
+			t.Errorf("%s: %v, want errorcode %d and %q", tt.name, err, tt.code, tt.contains)
+		}
+	}
+
+	stub := startStubKong(200, "{}")
+	stub.Close()
+	err := NewClient(stub.URL+"/", nil).Status()
+	if _, ok := err.(*Error); !ok || StatusCode(err) != 0 || IsNotFound(err) {
+		t.Errorf("unreachable admin API: %#v, want a kong Error without status", err)
+	}
+}
+
+func TestListEmptyCollection(t *testing.T) {
+	for _, reply := range []string{`{"data": {}}`, `{"data": []}`, `{"data": null}`, `{}`} {
+		stub := startStubKong(200, reply)
+		plugins, err := NewClient(stub.URL+"/", nil).ListPlugins()
+		stub.Close()
+		if err != nil || plugins == nil || len(plugins) != 0 {
+			t.Errorf("%s: %v %v, want an empty list", reply, plugins, err)
+		}
+	}
+}
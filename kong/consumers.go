@@ -0,0 +1,45 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package kong
+
+import "net/url"
+
+// ListConsumers returns every consumer registered with Kong.
+func (k *Client) ListConsumers() ([]Consumer, error) {
+	consumers := []Consumer{}
+	err := k.list(consumersPath, "consumers", &consumers)
+	return consumers, err
+}
+
+// GetConsumer returns the consumer with the given username or id.
+func (k *Client) GetConsumer(usernameOrID string) (*Consumer, error) {
+	c := &Consumer{}
+	err := k.send("GET", consumersPath+url.PathEscape(usernameOrID), "consumer "+usernameOrID, nil, c)
+	return c, err
+}
+
+// CreateConsumer registers a new consumer.
+func (k *Client) CreateConsumer(c *Consumer) (*Consumer, error) {
+	created := &Consumer{}
+	err := k.send("POST", consumersPath, "consumer "+c.Username, c, created)
+	return created, err
+}
+
+// DeleteConsumer removes the consumer with the given username or id together with its credentials.
+func (k *Client) DeleteConsumer(usernameOrID string) error {
+	return k.send("DELETE", consumersPath+url.PathEscape(usernameOrID), "consumer "+usernameOrID, nil, nil)
+}
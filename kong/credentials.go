@@ -0,0 +1,161 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package kong
+
+import "net/url"
+
+func credentialsPath(consumer string, kind string) string {
+	return consumersPath + url.PathEscape(consumer) + "/" + kind + "/"
+}
+
+// ListJWTCredentials returns the jwt credentials of the consumer with the given username or id.
+func (k *Client) ListJWTCredentials(consumer string) ([]JWTCredential, error) {
+	creds := []JWTCredential{}
+	err := k.list(credentialsPath(consumer, "jwt"), "jwt credentials of consumer "+consumer, &creds)
+	return creds, err
+}
+
+// CreateJWTCredential adds a jwt credential to the consumer. Kong generates the key and
+// secret when they are left empty.
+func (k *Client) CreateJWTCredential(consumer string, cred *JWTCredential) (*JWTCredential, error) {
+	created := &JWTCredential{}
+	if cred == nil {
+		cred = &JWTCredential{}
+	}
+	err := k.send("POST", credentialsPath(consumer, "jwt"), "jwt credential of consumer "+consumer, cred, created)
+	return created, err
+}
+
+// UpdateJWTCredential changes the jwt credential with the given key or id, e.g. to replace
+// the rsa public key.
+func (k *Client) UpdateJWTCredential(consumer string, keyOrID string, cred *JWTCredential) (*JWTCredential, error) {
+	updated := &JWTCredential{}
+	err := k.send("PATCH", credentialsPath(consumer, "jwt")+url.PathEscape(keyOrID), "jwt credential "+keyOrID+" of consumer "+consumer, cred, updated)
+	return updated, err
+}
+
+// DeleteJWTCredential removes the jwt credential with the given key or id from the consumer.
+func (k *Client) DeleteJWTCredential(consumer string, keyOrID string) error {
+	return k.send("DELETE", credentialsPath(consumer, "jwt")+url.PathEscape(keyOrID), "jwt credential "+keyOrID+" of consumer "+consumer, nil, nil)
+}
+
+// ListKeyAuthCredentials returns the api keys of the consumer with the given username or id.
+func (k *Client) ListKeyAuthCredentials(consumer string) ([]KeyAuthCredential, error) {
+	creds := []KeyAuthCredential{}
+	err := k.list(credentialsPath(consumer, "key-auth"), "key-auth credentials of consumer "+consumer, &creds)
+	return creds, err
+}
+
+// CreateKeyAuthCredential adds an api key to the consumer. Kong generates the key when it is
+// left empty.
+func (k *Client) CreateKeyAuthCredential(consumer string, cred *KeyAuthCredential) (*KeyAuthCredential, error) {
+	created := &KeyAuthCredential{}
+	if cred == nil {
+		cred = &KeyAuthCredential{}
+	}
+	err := k.send("POST", credentialsPath(consumer, "key-auth"), "key-auth credential of consumer "+consumer, cred, created)
+	return created, err
+}
+
+// DeleteKeyAuthCredential removes the api key with the given key or id from the consumer.
+func (k *Client) DeleteKeyAuthCredential(consumer string, keyOrID string) error {
+	return k.send("DELETE", credentialsPath(consumer, "key-auth")+url.PathEscape(keyOrID), "key-auth credential of consumer "+consumer, nil, nil)
+}
+
+// ListBasicAuthCredentials returns the basic-auth credentials of the consumer with the given
+// username or id.
+func (k *Client) ListBasicAuthCredentials(consumer string) ([]BasicAuthCredential, error) {
+	creds := []BasicAuthCredential{}
+	err := k.list(credentialsPath(consumer, "basic-auth"), "basic-auth credentials of consumer "+consumer, &creds)
+	return creds, err
+}
+
+// CreateBasicAuthCredential adds a username/password pair to the consumer.
+func (k *Client) CreateBasicAuthCredential(consumer string, cred *BasicAuthCredential) (*BasicAuthCredential, error) {
+	created := &BasicAuthCredential{}
+	err := k.send("POST", credentialsPath(consumer, "basic-auth"), "basic-auth credential "+cred.Username+" of consumer "+consumer, cred, created)
+	return created, err
+}
+
+// DeleteBasicAuthCredential removes the basic-auth credential with the given username or id
+// from the consumer.
+func (k *Client) DeleteBasicAuthCredential(consumer string, usernameOrID string) error {
+	return k.send("DELETE", credentialsPath(consumer, "basic-auth")+url.PathEscape(usernameOrID), "basic-auth credential "+usernameOrID+" of consumer "+consumer, nil, nil)
+}
+
+// ListHMACAuthCredentials returns the hmac-auth credentials of the consumer with the given
+// username or id.
+func (k *Client) ListHMACAuthCredentials(consumer string) ([]HMACAuthCredential, error) {
+	creds := []HMACAuthCredential{}
+	err := k.list(credentialsPath(consumer, "hmac-auth"), "hmac-auth credentials of consumer "+consumer, &creds)
+	return creds, err
+}
+
+// CreateHMACAuthCredential adds a username/secret pair to the consumer. Kong generates the
+// secret when it is left empty.
+func (k *Client) CreateHMACAuthCredential(consumer string, cred *HMACAuthCredential) (*HMACAuthCredential, error) {
+	created := &HMACAuthCredential{}
+	err := k.send("POST", credentialsPath(consumer, "hmac-auth"), "hmac-auth credential "+cred.Username+" of consumer "+consumer, cred, created)
+	return created, err
+}
+
+// DeleteHMACAuthCredential removes the hmac-auth credential with the given username or id from
+// the consumer.
+func (k *Client) DeleteHMACAuthCredential(consumer string, usernameOrID string) error {
+	return k.send("DELETE", credentialsPath(consumer, "hmac-auth")+url.PathEscape(usernameOrID), "hmac-auth credential "+usernameOrID+" of consumer "+consumer, nil, nil)
+}
+
+// ListOAuth2Credentials returns the oauth2 applications of the consumer with the given
+// username or id.
+func (k *Client) ListOAuth2Credentials(consumer string) ([]OAuth2Credential, error) {
+	creds := []OAuth2Credential{}
+	err := k.list(credentialsPath(consumer, "oauth2"), "oauth2 applications of consumer "+consumer, &creds)
+	return creds, err
+}
+
+// CreateOAuth2Credential registers an oauth2 application for the consumer. Kong generates the
+// client id and secret when they are left empty.
+func (k *Client) CreateOAuth2Credential(consumer string, cred *OAuth2Credential) (*OAuth2Credential, error) {
+	created := &OAuth2Credential{}
+	err := k.send("POST", credentialsPath(consumer, "oauth2"), "oauth2 application "+cred.Name+" of consumer "+consumer, cred, created)
+	return created, err
+}
+
+// DeleteOAuth2Credential removes the oauth2 application with the given client id or id from
+// the consumer.
+func (k *Client) DeleteOAuth2Credential(consumer string, clientIDOrID string) error {
+	return k.send("DELETE", credentialsPath(consumer, "oauth2")+url.PathEscape(clientIDOrID), "oauth2 application "+clientIDOrID+" of consumer "+consumer, nil, nil)
+}
+
+// ListACLs returns the acl groups the consumer with the given username or id belongs to.
+func (k *Client) ListACLs(consumer string) ([]ACL, error) {
+	acls := []ACL{}
+	err := k.list(credentialsPath(consumer, "acls"), "acls of consumer "+consumer, &acls)
+	return acls, err
+}
+
+// CreateACL adds the consumer to an acl group.
+func (k *Client) CreateACL(consumer string, group string) (*ACL, error) {
+	created := &ACL{}
+	err := k.send("POST", credentialsPath(consumer, "acls"), "acl "+group+" of consumer "+consumer, &ACL{Group: group}, created)
+	return created, err
+}
+
+// DeleteACL removes the consumer from the acl group with the given name or id.
+func (k *Client) DeleteACL(consumer string, groupOrID string) error {
+	return k.send("DELETE", credentialsPath(consumer, "acls")+url.PathEscape(groupOrID), "acl "+groupOrID+" of consumer "+consumer, nil, nil)
+}
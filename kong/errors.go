@@ -0,0 +1,123 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package kong
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Error is returned when the admin API cannot be reached or answers with a non-success status.
+type Error struct {
+	Method     string
+	Path       string
+	Object     string
+	StatusCode int
+	Message    string
+	Fields     map[string]string
+}
+
+func (e *Error) Error() string {
+	s := fmt.Sprintf("%s %s failed", e.Method, e.Path)
+	if e.Object != "" {
+		s = fmt.Sprintf("%s for %s", s, e.Object)
+	}
+	if e.StatusCode != 0 {
+		s = fmt.Sprintf("%s with errorcode %d", s, e.StatusCode)
+	}
+	if e.Message != "" {
+		s = fmt.Sprintf("%s: %s", s, e.Message)
+	}
+	if len(e.Fields) > 0 {
+		keys := []string{}
+		for k := range e.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := []string{}
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf("%s %s", k, e.Fields[k]))
+		}
+		s = fmt.Sprintf("%s (%s)", s, strings.Join(parts, ", "))
+	}
+	return s
+}
+
+// StatusCode returns the HTTP status carried by err, or 0 when err is not a Kong error.
+func StatusCode(err error) int {
+	if e, ok := err.(*Error); ok {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound reports whether err is a 404 from the admin API.
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409 from the admin API, i.e. the object already exists.
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
+
+// Kong reports failures either as {"message": "..."}, as field errors such as
+// {"name": "already exists with value 'x'"} or, for the newer entities, as both
+// {"message": "...", "fields": {...}}.
+func decodeError(e *Error, body []byte) {
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		e.Message = strings.TrimSpace(string(body))
+		return
+	}
+	fields := map[string]string{}
+	for k, v := range raw {
+		switch k {
+		case "message":
+			e.Message = fmt.Sprint(v)
+		case "fields":
+			if m, ok := v.(map[string]interface{}); ok {
+				for fk, fv := range m {
+					fields[fk] = fmt.Sprint(fv)
+				}
+			}
+		case "code", "name":
+			if _, ok := raw["message"]; ok {
+				continue
+			}
+			fields[k] = fmt.Sprint(v)
+		default:
+			fields[k] = fmt.Sprint(v)
+		}
+	}
+	if len(fields) > 0 {
+		e.Fields = fields
+	}
+}
+
+func readError(e *Error, resp *http.Response) *Error {
+	e.StatusCode = resp.StatusCode
+	body, err := ioutil.ReadAll(resp.Body)
+	if err == nil && len(body) > 0 {
+		decodeError(e, body)
+	}
+	return e
+}
@@ -0,0 +1,87 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package kong
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestErrorString(t *testing.T) {
+	tests := []struct {
+		err  Error
+		want string
+	}{
+		{Error{Method: "GET", Path: "services/"}, "GET services/ failed"},
+		{Error{Method: "POST", Path: "services/", Object: "service coredata", StatusCode: 409},
+			"POST services/ failed for service coredata with errorcode 409"},
+		{Error{Method: "GET", Path: "", Object: "node status", Message: "dial tcp: connection refused"},
+			"GET  failed for node status: dial tcp: connection refused"},
+		{Error{Method: "POST", Path: "consumers/", StatusCode: 400, Message: "schema violation", Fields: map[string]string{"username": "required field missing", "custom_id": "invalid"}},
+			"POST consumers/ failed with errorcode 400: schema violation (custom_id invalid, username required field missing)"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		body    string
+		message string
+		fields  map[string]string
+	}{
+		{`{"message": "Not found"}`, "Not found", nil},
+		{`{"name": "already exists with value 'coredata'"}`, "", map[string]string{"name": "already exists with value 'coredata'"}},
+		{`{"code": 2, "name": "schema violation", "message": "2 schema violations", "fields": {"host": "required field missing", "port": "value should be between 0 and 65535"}}`,
+			"2 schema violations", map[string]string{"host": "required field missing", "port": "value should be between 0 and 65535"}},
+		{"An unexpected error occurred\n", "An unexpected error occurred", nil},
+		{`{}`, "", nil},
+	}
+	for _, tt := range tests {
+		e := &Error{}
+		decodeError(e, []byte(tt.body))
+		if e.Message != tt.message || !reflect.DeepEqual(e.Fields, tt.fields) {
+			t.Errorf("decodeError(%s) = %q %v, want %q %v", tt.body, e.Message, e.Fields, tt.message, tt.fields)
+		}
+	}
+}
+
+func TestStatusPredicates(t *testing.T) {
+	tests := []struct {
+		err      error
+		status   int
+		notFound bool
+		conflict bool
+	}{
+		{nil, 0, false, false},
+		{errors.New("not a kong error"), 0, false, false},
+		{&Error{StatusCode: 404}, 404, true, false},
+		{&Error{StatusCode: 409}, 409, false, true},
+		{&Error{StatusCode: 500}, 500, false, false},
+		{&Error{Message: "connection refused"}, 0, false, false},
+	}
+	for _, tt := range tests {
+		if StatusCode(tt.err) != tt.status || IsNotFound(tt.err) != tt.notFound || IsConflict(tt.err) != tt.conflict {
+			t.Errorf("%v: StatusCode %d IsNotFound %v IsConflict %v, want %d %v %v",
+				tt.err, StatusCode(tt.err), IsNotFound(tt.err), IsConflict(tt.err), tt.status, tt.notFound, tt.conflict)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package kong
+
+import "net/url"
+
+// ListPlugins returns every plugin instance configured in Kong.
+func (k *Client) ListPlugins() ([]Plugin, error) {
+	plugins := []Plugin{}
+	err := k.list(pluginsPath, "plugins", &plugins)
+	return plugins, err
+}
+
+// ListServicePlugins returns the plugins scoped to the service with the given name or id.
+func (k *Client) ListServicePlugins(service string) ([]Plugin, error) {
+	plugins := []Plugin{}
+	err := k.list(servicesPath+url.PathEscape(service)+"/"+pluginsPath, "plugins of service "+service, &plugins)
+	return plugins, err
+}
+
+// CreatePlugin configures a plugin globally, or for the service, route or consumer set on p.
+func (k *Client) CreatePlugin(p *Plugin) (*Plugin, error) {
+	created := &Plugin{}
+	err := k.send("POST", pluginsPath, "plugin "+p.Name, p, created)
+	return created, err
+}
+
+// CreateServicePlugin enables a plugin on the service with the given name or id.
+func (k *Client) CreateServicePlugin(service string, p *Plugin) (*Plugin, error) {
+	created := &Plugin{}
+	err := k.send("POST", servicesPath+url.PathEscape(service)+"/"+pluginsPath, "plugin "+p.Name+" of service "+service, p, created)
+	return created, err
+}
+
+// UpdatePlugin patches the non-empty fields of p onto the plugin with the given id.
+func (k *Client) UpdatePlugin(id string, p *Plugin) (*Plugin, error) {
+	updated := &Plugin{}
+	err := k.send("PATCH", pluginsPath+url.PathEscape(id), "plugin "+id, p, updated)
+	return updated, err
+}
+
+// DeletePlugin removes the plugin with the given id.
+func (k *Client) DeletePlugin(id string) error {
+	return k.send("DELETE", pluginsPath+url.PathEscape(id), "plugin "+id, nil, nil)
+}
@@ -0,0 +1,52 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package kong
+
+import "net/url"
+
+// ListRoutes returns every route registered with Kong.
+func (k *Client) ListRoutes() ([]Route, error) {
+	routes := []Route{}
+	err := k.list(routesPath, "routes", &routes)
+	return routes, err
+}
+
+// ListServiceRoutes returns the routes of the service with the given name or id.
+func (k *Client) ListServiceRoutes(service string) ([]Route, error) {
+	routes := []Route{}
+	err := k.list(servicesPath+url.PathEscape(service)+"/"+routesPath, "routes of service "+service, &routes)
+	return routes, err
+}
+
+// CreateRoute adds a route to the service with the given name or id.
+func (k *Client) CreateRoute(service string, r *Route) (*Route, error) {
+	created := &Route{}
+	err := k.send("POST", servicesPath+url.PathEscape(service)+"/"+routesPath, "route of service "+service, r, created)
+	return created, err
+}
+
+// UpdateRoute patches the non-empty fields of r onto the route with the given id.
+func (k *Client) UpdateRoute(id string, r *Route) (*Route, error) {
+	updated := &Route{}
+	err := k.send("PATCH", routesPath+url.PathEscape(id), "route "+id, r, updated)
+	return updated, err
+}
+
+// DeleteRoute removes the route with the given id.
+func (k *Client) DeleteRoute(id string) error {
+	return k.send("DELETE", routesPath+url.PathEscape(id), "route "+id, nil, nil)
+}
@@ -0,0 +1,52 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package kong
+
+import "net/url"
+
+// ListServices returns the services registered with Kong.
+func (k *Client) ListServices() ([]Service, error) {
+	services := []Service{}
+	err := k.list(servicesPath, "services", &services)
+	return services, err
+}
+
+// GetService returns the service with the given name or id.
+func (k *Client) GetService(nameOrID string) (*Service, error) {
+	s := &Service{}
+	err := k.send("GET", servicesPath+url.PathEscape(nameOrID), "service "+nameOrID, nil, s)
+	return s, err
+}
+
+// CreateService registers a new service.
+func (k *Client) CreateService(s *Service) (*Service, error) {
+	created := &Service{}
+	err := k.send("POST", servicesPath, "service "+s.Name, s, created)
+	return created, err
+}
+
+// UpdateService patches the non-empty fields of s onto the service with the given name or id.
+func (k *Client) UpdateService(nameOrID string, s *Service) (*Service, error) {
+	updated := &Service{}
+	err := k.send("PATCH", servicesPath+url.PathEscape(nameOrID), "service "+nameOrID, s, updated)
+	return updated, err
+}
+
+// DeleteService removes the service with the given name or id.
+func (k *Client) DeleteService(nameOrID string) error {
+	return k.send("DELETE", servicesPath+url.PathEscape(nameOrID), "service "+nameOrID, nil, nil)
+}
@@ -0,0 +1,131 @@
+/*******************************************************************************
+ * Copyright 2018 Dell Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *
+ * @author: Tingyu Zeng, Dell
+ * @version: 0.1.0
+ *******************************************************************************/
+package kong
+
+// Service is an upstream API registered with Kong.
+type Service struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Host      string `json:"host,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	Protocol  string `json:"protocol,omitempty"`
+	Path      string `json:"path,omitempty"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+}
+
+// ServiceRef points a route at the service it belongs to.
+type ServiceRef struct {
+	ID string `json:"id"`
+}
+
+// Route matches incoming requests to a service.
+type Route struct {
+	ID        string      `json:"id,omitempty"`
+	Paths     []string    `json:"paths,omitempty"`
+	Hosts     []string    `json:"hosts,omitempty"`
+	Methods   []string    `json:"methods,omitempty"`
+	Protocols []string    `json:"protocols,omitempty"`
+	Service   *ServiceRef `json:"service,omitempty"`
+	CreatedAt int64       `json:"created_at,omitempty"`
+}
+
+// Plugin is a plugin instance, optionally scoped to a service, route or consumer.
+type Plugin struct {
+	ID         string                 `json:"id,omitempty"`
+	Name       string                 `json:"name,omitempty"`
+	ServiceID  string                 `json:"service_id,omitempty"`
+	RouteID    string                 `json:"route_id,omitempty"`
+	ConsumerID string                 `json:"consumer_id,omitempty"`
+	Config     map[string]interface{} `json:"config,omitempty"`
+	Enabled    *bool                  `json:"enabled,omitempty"`
+	CreatedAt  int64                  `json:"created_at,omitempty"`
+}
+
+// Consumer is an account that credentials are attached to.
+type Consumer struct {
+	ID        string `json:"id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	CustomID  string `json:"custom_id,omitempty"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+}
+
+// JWTCredential is a key/secret pair used by the jwt plugin to verify tokens of a consumer.
+type JWTCredential struct {
+	ID           string `json:"id,omitempty"`
+	ConsumerID   string `json:"consumer_id,omitempty"`
+	Key          string `json:"key,omitempty"`
+	Secret       string `json:"secret,omitempty"`
+	Algorithm    string `json:"algorithm,omitempty"`
+	RSAPublicKey string `json:"rsa_public_key,omitempty"`
+	CreatedAt    int64  `json:"created_at,omitempty"`
+}
+
+// KeyAuthCredential is an api key checked by the key-auth plugin.
+type KeyAuthCredential struct {
+	ID         string `json:"id,omitempty"`
+	ConsumerID string `json:"consumer_id,omitempty"`
+	Key        string `json:"key,omitempty"`
+	CreatedAt  int64  `json:"created_at,omitempty"`
+}
+
+// BasicAuthCredential is a username/password pair checked by the basic-auth plugin. Kong
+// returns the password hashed.
+type BasicAuthCredential struct {
+	ID         string `json:"id,omitempty"`
+	ConsumerID string `json:"consumer_id,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	CreatedAt  int64  `json:"created_at,omitempty"`
+}
+
+// HMACAuthCredential is a username/secret pair used by the hmac-auth plugin to verify request
+// signatures.
+type HMACAuthCredential struct {
+	ID         string `json:"id,omitempty"`
+	ConsumerID string `json:"consumer_id,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Secret     string `json:"secret,omitempty"`
+	CreatedAt  int64  `json:"created_at,omitempty"`
+}
+
+// OAuth2Credential is an application of a consumer that obtains tokens from the oauth2 plugin.
+type OAuth2Credential struct {
+	ID           string   `json:"id,omitempty"`
+	ConsumerID   string   `json:"consumer_id,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	ClientID     string   `json:"client_id,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	RedirectURI  []string `json:"redirect_uri,omitempty"`
+	CreatedAt    int64    `json:"created_at,omitempty"`
+}
+
+// ACL puts a consumer into a group checked by the acl plugin.
+type ACL struct {
+	ID         string `json:"id,omitempty"`
+	ConsumerID string `json:"consumer_id,omitempty"`
+	Group      string `json:"group,omitempty"`
+	CreatedAt  int64  `json:"created_at,omitempty"`
+}
+
+// Certificate is a TLS certificate/key pair served for the listed SNIs.
+type Certificate struct {
+	ID        string   `json:"id,omitempty"`
+	Cert      string   `json:"cert,omitempty"`
+	Key       string   `json:"key,omitempty"`
+	Snis      []string `json:"snis,omitempty"`
+	CreatedAt int64    `json:"created_at,omitempty"`
+}